@@ -0,0 +1,309 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aquasecurity/binfinder/pkg/layerindex"
+)
+
+// BaseComparison is what an image changes in the unmanaged binaries of the image it is built from
+type BaseComparison struct {
+	Image string
+	// Detected is true when the base image was found from the layers rather than given by -base
+	Detected bool
+	// Added are the unmanaged binaries missing from the base image
+	Added []string `json:",omitempty"`
+	// Removed are the unmanaged binaries of the base image the image no longer has
+	Removed []string `json:",omitempty"`
+	// Changed are the unmanaged binaries whose content differs from the base image
+	Changed []BinaryChange `json:",omitempty"`
+	// Inherited are the unmanaged binaries the base image already has unchanged,
+	// left out of ELFNames unless -keep-inherited is set
+	Inherited []string `json:",omitempty"`
+}
+
+// BinaryChange is an unmanaged binary replaced on top of the base image
+type BinaryChange struct {
+	Path       string
+	BaseSHA256 string
+	SHA256     string
+}
+
+// diffFileName returns the path of the diff file of the image
+func diffFileName(imageName string) string {
+	return fmt.Sprintf("%v/%v", *outputDir, strings.ReplaceAll(imageName, "/", "-")+"-diff.json")
+}
+
+func loadDiff(name string) (*Diffs, error) {
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var d Diffs
+	if err = json.Unmarshal(b, &d); err != nil {
+		return nil, fmt.Errorf("%v: invalid diff file: %v", name, err)
+	}
+	return &d, nil
+}
+
+// detectBase returns the diff of the indexed image the image is built from, or
+// of one of its aliases, or nil when none of them was scanned
+func detectBase(d Diffs) *Diffs {
+	if d.BuiltFrom == nil {
+		return nil
+	}
+	for _, name := range append([]string{d.BuiltFrom.Image}, d.BuiltFrom.Aliases...) {
+		base, err := loadDiff(diffFileName(name))
+		if err == nil {
+			return base
+		}
+		if !os.IsNotExist(err) {
+			log.Printf("%v: error loading diff of base image: %v\n", d.ImageName, err)
+		}
+	}
+	return nil
+}
+
+// compareWithBase returns the changes of the image to the unmanaged binaries
+// of the -base image, or of the base image detected from the layers
+func compareWithBase(d Diffs) *BaseComparison {
+	if *baseImage != "" {
+		if d.ImageName == *baseImage {
+			return nil
+		}
+		base, err := loadDiff(diffFileName(*baseImage))
+		if err != nil {
+			log.Printf("%v: error loading diff of base image: %v\n", d.ImageName, err)
+			return nil
+		}
+		return compareBinaries(d, *base, false)
+	}
+	if base := detectBase(d); base != nil {
+		return compareBinaries(d, *base, true)
+	}
+	return nil
+}
+
+// compareBinaries lists the unmanaged binaries added, removed and changed on top of the base image
+func compareBinaries(d Diffs, base Diffs, detected bool) *BaseComparison {
+	c := &BaseComparison{Image: base.ImageName, Detected: detected}
+	hashes := func(d Diffs) map[string]string {
+		m := make(map[string]string)
+		for _, b := range d.Binaries {
+			m[b.Path] = b.SHA256
+		}
+		return m
+	}
+	baseHashes, imageHashes := hashes(base), hashes(d)
+	inBase := unmanaged(base)
+	inImage := unmanaged(d)
+	for p := range inImage {
+		if !inBase[p] {
+			c.Added = append(c.Added, p)
+			continue
+		}
+		// without both hashes the binary is taken as unchanged
+		if b, i := baseHashes[p], imageHashes[p]; b != "" && i != "" && b != i {
+			c.Changed = append(c.Changed, BinaryChange{Path: p, BaseSHA256: b, SHA256: i})
+			continue
+		}
+		c.Inherited = append(c.Inherited, p)
+	}
+	for p := range inBase {
+		if !inImage[p] {
+			c.Removed = append(c.Removed, p)
+		}
+	}
+	sort.Strings(c.Added)
+	sort.Strings(c.Removed)
+	sort.Strings(c.Inherited)
+	sort.Slice(c.Changed, func(i, j int) bool { return c.Changed[i].Path < c.Changed[j].Path })
+	return c
+}
+
+// unmanaged returns the unmanaged binaries of the image, including those
+// inherited from its base image and left out of ELFNames
+func unmanaged(d Diffs) map[string]bool {
+	m := make(map[string]bool)
+	for _, p := range d.ELFNames {
+		m[p] = true
+	}
+	if d.Base != nil {
+		for _, p := range d.Base.Inherited {
+			m[p] = true
+		}
+	}
+	return m
+}
+
+// dropInherited leaves the binaries inherited unchanged from the base image
+// out of ELFNames, so that the diff and the analysis only count what the image adds
+func dropInherited(d *Diffs) {
+	if d.Base == nil || len(d.Base.Inherited) == 0 {
+		return
+	}
+	inherited := make(map[string]bool)
+	for _, p := range d.Base.Inherited {
+		inherited[p] = true
+	}
+	var names []string
+	for _, p := range d.ELFNames {
+		if !inherited[p] {
+			names = append(names, p)
+		}
+	}
+	d.ELFNames = names
+}
+
+// inheritedOnly returns the binaries inherited from the base image that are not
+// listed in ELFNames, whose findings the analysis leaves out
+func inheritedOnly(d Diffs) map[string]bool {
+	m := make(map[string]bool)
+	if d.Base == nil {
+		return m
+	}
+	listed := make(map[string]bool)
+	for _, p := range d.ELFNames {
+		listed[p] = true
+	}
+	for _, p := range d.Base.Inherited {
+		if !listed[p] {
+			m[p] = true
+		}
+	}
+	return m
+}
+
+// indexReferenceImages adds the layers of the reference images to the layer index
+func indexReferenceImages(names []string) {
+	for _, name := range names {
+		img, _, err := cli.ImageInspectWithRaw(context.Background(), name)
+		if err != nil {
+			if err = pullImage(name); err == nil {
+				img, _, err = cli.ImageInspectWithRaw(context.Background(), name)
+			}
+		}
+		if err != nil {
+			log.Printf("%v: error inspecting reference image: %v\n", name, err)
+			continue
+		}
+		if err = layerIndex.Add(layerindex.Image{Image: name, Layers: img.RootFS.Layers, Reference: true}); err != nil {
+			log.Printf("%v: error saving layer index: %v\n", name, err)
+		}
+	}
+	log.Printf("layer index holds %v images", layerIndex.Size())
+}
+
+// scanWaves groups the images so that the images of a wave are only built on
+// images of earlier waves: scanned wave after wave, every image finds the diff
+// of the base image detected from its layers already written
+func scanWaves(names []string, layers map[string][]string) [][]string {
+	byLayers := append([]string{}, names...)
+	sort.SliceStable(byLayers, func(i, j int) bool { return len(layers[byLayers[i]]) < len(layers[byLayers[j]]) })
+	wave := make(map[string]int)
+	last := 0
+	for i, name := range byLayers {
+		for _, base := range byLayers[:i] {
+			if layerindex.IsPrefix(layers[base], layers[name]) && wave[base]+1 > wave[name] {
+				wave[name] = wave[base] + 1
+			}
+		}
+		if wave[name] > last {
+			last = wave[name]
+		}
+	}
+	waves := make([][]string, last+1)
+	for _, name := range names {
+		waves[wave[name]] = append(waves[wave[name]], name)
+	}
+	return waves
+}
+
+// imageLayers returns the layers of the images, pulled when missing, leaving
+// out the images that cannot be inspected
+func imageLayers(names []string) map[string][]string {
+	layers := make(map[string][]string)
+	for _, name := range names {
+		img, _, err := cli.ImageInspectWithRaw(context.Background(), name)
+		if err != nil {
+			if err = pullImage(name); err == nil {
+				img, _, err = cli.ImageInspectWithRaw(context.Background(), name)
+			}
+		}
+		if err != nil {
+			log.Printf("%v: error inspecting image: %v\n", name, err)
+			continue
+		}
+		layers[name] = img.RootFS.Layers
+	}
+	return layers
+}
+
+// baseGroup adds up the analyzed images built from the same base image
+type baseGroup struct {
+	images    int64
+	binaries  int64
+	bytes     int64
+	withAdded int64
+}
+
+// unknownBase groups the images whose base image was not identified
+const unknownBase = "unknown"
+
+func groupByBase(d Diffs, groups map[string]*baseGroup) {
+	base := unknownBase
+	if d.BuiltFrom != nil {
+		base = d.BuiltFrom.Image
+	}
+	g, ok := groups[base]
+	if !ok {
+		g = &baseGroup{}
+		groups[base] = g
+	}
+	g.images++
+	g.binaries += int64(len(d.ELFNames))
+	if d.UnmanagedSize != nil {
+		g.bytes += d.UnmanagedSize.Bytes
+	}
+	if d.Base != nil && len(d.Base.Added) > 0 {
+		g.withAdded++
+	}
+}
+
+// exportBaseGroups writes the analyzed images grouped by base image, most used first
+func exportBaseGroups(outputFile string, groups map[string]*baseGroup) {
+	var names []string
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if groups[names[i]].images != groups[names[j]].images {
+			return groups[names[i]].images > groups[names[j]].images
+		}
+		return names[i] < names[j]
+	})
+	f, err := os.Create(outputFile)
+	if err != nil {
+		log.Printf("error exporting base images, got error: %v", err)
+		return
+	}
+	defer f.Close()
+	w := csv.NewWriter(f)
+	defer w.Flush()
+	_ = w.Write([]string{"base_image", "images", "unmanaged_binaries", "unmanaged_bytes", "images_adding_binaries"})
+	for _, name := range names {
+		g := groups[name]
+		if err = w.Write([]string{name, fmt.Sprintf("%v", g.images), fmt.Sprintf("%v", g.binaries),
+			fmt.Sprintf("%v", g.bytes), fmt.Sprintf("%v", g.withAdded)}); err != nil {
+			log.Printf("error writing row to CSV base images, got error: %v", err)
+		}
+	}
+}
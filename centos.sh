@@ -1,2 +1,9 @@
 yum install -y file > /dev/null 2>&1
-find / -executable -type f -exec file {} \; | grep -i elf
\ No newline at end of file
+# $1 is elf to only report ELF files, all to also report the -artifacts candidates
+filter=$1
+shift
+if [ "$filter" = all ]; then
+	find "$@" -exec file {} \;
+else
+	find "$@" -exec file {} \; | grep -i elf
+fi
\ No newline at end of file
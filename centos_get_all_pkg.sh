@@ -1 +1,2 @@
-rpm -qa | xargs -I % sh -c 'rpm -ql %'
\ No newline at end of file
+[ -d /var/lib/rpm ] || { echo "/var/lib/rpm: No such file or directory" >&2; exit 1; }
+rpm -qa --qf '[%{NAME}\t%{FILENAMES}\n]'
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/aquasecurity/binfinder/pkg/dockerfile"
+)
+
+// DockerfileCheck cross-checks the unmanaged binaries of an image with the instructions of its -dockerfile
+type DockerfileCheck struct {
+	File        string
+	Predictions []PredictionCheck `json:",omitempty"`
+	// Unexplained are the unmanaged binaries no instruction of the Dockerfile accounts for
+	Unexplained []string `json:",omitempty"`
+}
+
+// PredictionCheck is a Dockerfile instruction and the unmanaged binaries it left in the image
+type PredictionCheck struct {
+	dockerfile.Prediction
+	Found []string `json:",omitempty"`
+}
+
+// loadPredictions returns the instructions of the Dockerfile introducing binaries outside the package manager
+func loadPredictions(name string) ([]dockerfile.Prediction, error) {
+	instructions, err := dockerfile.Load(name)
+	if err != nil {
+		return nil, err
+	}
+	return dockerfile.Predict(instructions), nil
+}
+
+// printPredictions reports the Dockerfile instructions when no image is scanned
+func printPredictions(name string, predictions []dockerfile.Prediction) {
+	fmt.Printf("%v: %v instructions introduce binaries outside the package manager\n", name, len(predictions))
+	for _, p := range predictions {
+		fmt.Printf("%v:%v: %v %v\n  %v\n", name, p.Line, p.Kind, p.Paths, p.Instruction)
+	}
+}
+
+// checkDockerfile matches the unmanaged binaries of the image with the -dockerfile predictions
+func checkDockerfile(d Diffs) *DockerfileCheck {
+	if *dockerfileName == "" {
+		return nil
+	}
+	c := &DockerfileCheck{File: *dockerfileName}
+	explained := make(map[string]bool)
+	for _, p := range predictions {
+		pc := PredictionCheck{Prediction: p}
+		for _, b := range d.ELFNames {
+			if p.Matches(b) {
+				pc.Found = append(pc.Found, b)
+				explained[b] = true
+			}
+		}
+		c.Predictions = append(c.Predictions, pc)
+	}
+	for _, b := range d.ELFNames {
+		if !explained[b] {
+			c.Unexplained = append(c.Unexplained, b)
+		}
+	}
+	sort.Strings(c.Unexplained)
+	return c
+}
@@ -0,0 +1,499 @@
+package main
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os/exec"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+
+	"github.com/aquasecurity/binfinder/pkg/artifact"
+	"github.com/aquasecurity/binfinder/pkg/catalog"
+	"github.com/aquasecurity/binfinder/pkg/declare"
+	"github.com/aquasecurity/binfinder/pkg/elfinfo"
+	"github.com/aquasecurity/binfinder/pkg/layerindex"
+	"github.com/aquasecurity/binfinder/pkg/ldso"
+	"github.com/aquasecurity/binfinder/pkg/pkgindex"
+	"github.com/aquasecurity/binfinder/pkg/privilege"
+	"github.com/aquasecurity/binfinder/pkg/provenance"
+	"github.com/aquasecurity/binfinder/pkg/reach"
+	"github.com/aquasecurity/binfinder/pkg/rootfs"
+	"github.com/aquasecurity/binfinder/pkg/shadow"
+	"github.com/aquasecurity/binfinder/pkg/toolchain"
+	"github.com/aquasecurity/binfinder/pkg/versions"
+	"github.com/aquasecurity/binfinder/pkg/vuln"
+)
+
+// exportImage snapshots the root filesystem of the image through a created,
+// never started, container
+func exportImage(imageName string) (*rootfs.FS, error) {
+	out, err := exec.Command("docker",
+		strings.Split(fmt.Sprintf(argsCreateContainer, imageName), " ")...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("creating container: %v", err)
+	}
+	containerID := strings.TrimSpace(string(out))
+	defer func() {
+		_ = exec.Command("docker",
+			strings.Split(fmt.Sprintf(argsRemoveContainer, containerID), " ")...).Run()
+	}()
+
+	cmd := exec.Command("docker", strings.Split(fmt.Sprintf(argsExportContainer, containerID), " ")...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err = cmd.Start(); err != nil {
+		return nil, fmt.Errorf("exporting container: %v", err)
+	}
+	fs, err := rootfs.Load(stdout, "")
+	if werr := cmd.Wait(); err == nil && werr != nil {
+		err = fmt.Errorf("exporting container: %v", werr)
+	}
+	if err != nil {
+		if fs != nil {
+			fs.Close()
+		}
+		return nil, err
+	}
+	return fs, nil
+}
+
+// imageScan is the state shared by the inspections of one image
+type imageScan struct {
+	fs       *rootfs.FS
+	osName   string
+	pkgFiles map[string]string
+	pkgDirs  map[string]bool
+	diff     *Diffs
+
+	libc     string
+	resolver *ldso.Resolver
+	// packaged are the executable files installed by packages
+	packaged []pkgindex.File
+	// image is the configuration and metadata of the image
+	image types.ImageInspect
+	// history are the commands that created the image layers, newest first
+	history []string
+}
+
+func newImageScan(fs *rootfs.FS, pkgFiles map[string]string, osName string, diffJson *Diffs) *imageScan {
+	s := &imageScan{fs: fs, osName: osName, pkgFiles: pkgFiles, pkgDirs: make(map[string]bool), diff: diffJson}
+	for f := range pkgFiles {
+		s.pkgDirs[path.Dir(f)] = true
+	}
+	s.libc = imageLibc(osName)
+	s.resolver = ldso.NewResolver(fs, s.libc)
+	return s
+}
+
+// imageLibc returns the C library of the distribution detected by getOS
+func imageLibc(osName string) string {
+	if strings.Contains(strings.ToLower(osName), "alpine") {
+		return ldso.LibcMusl
+	}
+	return ldso.LibcGlibc
+}
+
+// usrMerged are the top level directories that merged-/usr distributions link into /usr
+var usrMerged = []string{"/bin/", "/sbin/", "/lib/", "/lib32/", "/lib64/", "/libx32/"}
+
+// owner returns the package that installed the file, or an empty string.
+// Package databases may record a file under a symlinked or merged-/usr path.
+func (s *imageScan) owner(name string) string {
+	candidates := []string{name}
+	if resolved, err := s.fs.Resolve(name); err == nil && resolved != name {
+		candidates = append(candidates, resolved)
+	}
+	for _, c := range candidates {
+		for _, d := range usrMerged {
+			if strings.HasPrefix(c, d) {
+				candidates = append(candidates, "/usr"+c)
+			} else if strings.HasPrefix(c, "/usr"+d) {
+				candidates = append(candidates, strings.TrimPrefix(c, "/usr"))
+			}
+		}
+	}
+	for _, c := range candidates {
+		if pkg, ok := s.pkgFiles[c]; ok {
+			return pkg
+		}
+	}
+	return ""
+}
+
+// managed reports whether a file, or a directory holding files, was installed by a package
+func (s *imageScan) managed(name string) bool {
+	return s.owner(name) != "" || s.pkgDirs[name]
+}
+
+// inspectImage examines the content of the image filesystem and of the files reported by findBins
+func inspectImage(pkgELFFiles map[string]string, osName string, imageName string, diffJson *Diffs) {
+	image, _, err := cli.ImageInspectWithRaw(context.Background(), imageName)
+	if err != nil {
+		log.Printf("%v: %s OS, error inspecting image: %v\n", imageName, osName, err)
+	}
+	diffJson.Layers = image.RootFS.Layers
+	identifyBase(diffJson)
+	if *listOnly && len(diffJson.artifactCandidates) == 0 {
+		return
+	}
+
+	fs, err := exportImage(imageName)
+	if err != nil {
+		log.Printf("%v: %s OS, error exporting filesystem: %v\n", imageName, osName, err)
+		return
+	}
+	defer fs.Close()
+
+	s := newImageScan(fs, pkgELFFiles, osName, diffJson)
+	s.image = image
+	if *listOnly {
+		s.classifyArtifacts()
+		return
+	}
+	history, err := cli.ImageHistory(context.Background(), imageName)
+	if err != nil {
+		log.Printf("%v: %s OS, error reading image history: %v\n", imageName, osName, err)
+	}
+	for _, h := range history {
+		s.history = append(s.history, h.CreatedBy)
+	}
+	s.hashPackagedFiles()
+	s.inspectBinaries()
+	if packageIndex != nil {
+		if err = packageIndex.Add(pkgindex.Image{Image: imageName, Files: s.packaged}); err != nil {
+			log.Printf("%v: %s OS, error saving package index: %v\n", imageName, osName, err)
+		}
+	}
+	s.probeBinaries()
+	s.inspectPrivileges()
+	s.auditLibrarySearchPaths()
+	s.findPathShadowing()
+	s.tagReachability()
+	s.classifyProvenance()
+	s.readRelease()
+	s.findBuildLeftovers()
+	s.classifyArtifacts()
+	s.applyDeclarations()
+}
+
+// identifyBase records the indexed image the image is built from, and indexes the layers of the image
+func identifyBase(d *Diffs) {
+	if layerIndex == nil || len(d.Layers) == 0 {
+		return
+	}
+	if d.BuiltFrom = layerIndex.Lookup(d.ImageName, d.Layers); d.BuiltFrom != nil {
+		log.Printf("%v: built from %v\n", d.ImageName, d.BuiltFrom.Image)
+	}
+	if err := layerIndex.Add(layerindex.Image{Image: d.ImageName, Layers: d.Layers}); err != nil {
+		log.Printf("%v: error saving layer index: %v\n", d.ImageName, err)
+	}
+}
+
+// hashPackagedFiles records the hash and build ID of the executables installed by packages
+func (s *imageScan) hashPackagedFiles() {
+	names := make([]string, 0, len(s.pkgFiles))
+	for name := range s.pkgFiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		e, ok := s.fs.Lstat(name)
+		if !ok || e.Header.Typeflag != tar.TypeReg || e.Header.Mode&0111 == 0 {
+			continue
+		}
+		f := pkgindex.File{Path: name, Package: s.pkgFiles[name]}
+		var err error
+		if f.SHA256, f.BuildID, err = s.fingerprint(name); err != nil {
+			continue
+		}
+		s.packaged = append(s.packaged, f)
+	}
+}
+
+// fingerprint returns the sha256 of a file, and its build ID when it is an ELF file
+func (s *imageScan) fingerprint(name string) (string, string, error) {
+	r, err := s.fs.Open(name)
+	if err != nil {
+		return "", "", err
+	}
+	h := sha256.New()
+	if _, err = io.Copy(h, io.NewSectionReader(r, 0, r.Size())); err != nil {
+		return "", "", err
+	}
+	buildID := ""
+	if info, err := elfinfo.Read(r); err == nil {
+		buildID = info.BuildID
+		info.Close()
+	}
+	return hex.EncodeToString(h.Sum(nil)), buildID, nil
+}
+
+func (s *imageScan) inspectBinaries() {
+	sort.Strings(s.diff.ELFNames)
+	s.diff.UnmanagedSize = &SizeTotals{}
+	for _, p := range s.diff.ELFNames {
+		b := Binary{Path: p}
+		if err := s.inspectBinary(&b); err != nil {
+			log.Printf("%v: error inspecting %v: %v\n", s.diff.ImageName, p, err)
+		}
+		if err := s.matchRules(&b); err != nil {
+			log.Printf("%v: error matching rules on %v: %v\n", s.diff.ImageName, p, err)
+		}
+		if b.Size != nil {
+			s.diff.UnmanagedSize.add(*b.Size)
+		}
+		s.diff.Binaries = append(s.diff.Binaries, b)
+	}
+}
+
+// inspectBinary fills the binary record from the ELF file at b.Path
+func (s *imageScan) inspectBinary(b *Binary) error {
+	r, err := s.fs.Open(b.Path)
+	if err != nil {
+		return err
+	}
+	h := sha256.New()
+	if _, err = io.Copy(h, io.NewSectionReader(r, 0, r.Size())); err != nil {
+		return err
+	}
+	b.SHA256 = hex.EncodeToString(h.Sum(nil))
+	info, err := elfinfo.Read(r)
+	if err != nil {
+		return err
+	}
+	defer info.Close()
+	b.BuildID = info.BuildID
+	b.RelocatedFrom = pkgindex.Match(s.packaged, b.SHA256, b.BuildID)
+	if b.RelocatedFrom == nil && packageIndex != nil {
+		b.RelocatedFrom = packageIndex.Lookup(b.SHA256, b.BuildID, s.diff.ImageName)
+	}
+	if b.RelocatedFrom != nil {
+		log.Printf("%v: %v is %v\n", s.diff.ImageName, b.Path, b.RelocatedFrom.Label)
+	}
+	if releases != nil {
+		if b.Release = releases.Lookup(b.SHA256, b.BuildID); b.Release != nil {
+			log.Printf("%v: %v is %v\n", s.diff.ImageName, b.Path, b.Release.Label)
+		}
+	}
+	b.Hardening = &info.Hardening
+	size := info.Size(r.Size())
+	b.Size = &size
+	if p := info.Packing(r, r.Size()); p.Suspicious() {
+		log.Printf("%v: %v looks packed or obfuscated\n", s.diff.ImageName, b.Path)
+		b.Packing = &p
+	}
+	s.diff.LibraryHijacks = append(s.diff.LibraryHijacks, s.ldsoAuditor().Binary(b.Path, info.RPath, info.RunPath)...)
+	if m := s.resolver.CheckLibc(b.Path, s.libc, info.Interpreter, info.Needed, info.RPath, info.RunPath); m != nil {
+		s.diff.LibcMismatches = append(s.diff.LibcMismatches, *m)
+	}
+	b.Dependencies = s.resolver.Dependencies(b.Path, info, s.owner)
+	b.Components = binaryComponents(info, b.Release)
+	data := info.ReadOnlyData()
+	if data == nil {
+		// no section headers, the strings are somewhere in the file
+		if data, err = s.fs.ReadFile(b.Path); err != nil {
+			return err
+		}
+	}
+	b.VersionStrings = versions.Extract(data, b.Path)
+	if vulnDB != nil {
+		b.Vulnerabilities = vulnDB.Match(b.Components)
+		for _, v := range b.Vulnerabilities {
+			log.Printf("%v: %v is affected by %v through %v %v\n", s.diff.ImageName, b.Path, v.ID,
+				v.Component.Name, v.Component.Version)
+		}
+	}
+	return nil
+}
+
+// binaryComponents returns the components identified in the binary, named and
+// versioned as in OSV advisories
+func binaryComponents(info *elfinfo.Info, release *catalog.Match) []vuln.Component {
+	var components []vuln.Component
+	if bi := info.GoBuildInfo(); bi != nil {
+		if v := strings.TrimPrefix(bi.GoVersion, "go"); v != bi.GoVersion {
+			components = append(components, vuln.Component{Ecosystem: vuln.EcosystemGo, Name: vuln.GoStdlib, Version: v})
+		}
+		if bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+			components = append(components, vuln.Component{Ecosystem: vuln.EcosystemGo, Name: bi.Main.Path,
+				Version: strings.TrimPrefix(bi.Main.Version, "v")})
+		}
+		for _, m := range bi.Deps {
+			components = append(components, vuln.Component{Ecosystem: vuln.EcosystemGo, Name: m.Path,
+				Version: strings.TrimPrefix(m.Version, "v")})
+		}
+	}
+	for _, p := range info.RustPackages() {
+		if p.Source == "crates.io" && p.Kind != "build" {
+			components = append(components, vuln.Component{Ecosystem: vuln.EcosystemCrate, Name: p.Name, Version: p.Version})
+		}
+	}
+	if release != nil && release.Ecosystem != "" {
+		components = append(components, vuln.Component{Ecosystem: release.Ecosystem, Name: release.Package,
+			Version: release.Version})
+	}
+	return components
+}
+
+// inspectPrivileges reports the unmanaged files that raise the privileges of their caller
+func (s *imageScan) inspectPrivileges() {
+	names := append(append([]string{}, s.diff.ELFNames...), s.diff.artifactCandidates...)
+	sort.Strings(names)
+	for _, p := range names {
+		e, ok := s.fs.Lstat(p)
+		if !ok {
+			continue
+		}
+		priv, err := privilege.Inspect(p, e.Header)
+		if err != nil {
+			log.Printf("%v: error reading capabilities of %v: %v\n", s.diff.ImageName, p, err)
+		}
+		if priv != nil {
+			log.Printf("%v: %v is privileged, mode %v\n", s.diff.ImageName, p, priv.Mode)
+			s.diff.Privileged = append(s.diff.Privileged, *priv)
+		}
+	}
+}
+
+// matchRules records the rules of the -rules directory matching the content of the binary
+func (s *imageScan) matchRules(b *Binary) error {
+	if ruleSet == nil {
+		return nil
+	}
+	content, err := s.fs.ReadFile(b.Path)
+	if err != nil {
+		return err
+	}
+	b.RuleHits = ruleSet.Match(content)
+	for _, h := range b.RuleHits {
+		log.Printf("%v: %v matches rule %v (%v)\n", s.diff.ImageName, b.Path, h.Rule, h.Severity)
+	}
+	return nil
+}
+
+// imageEnv returns the environment of the image configuration
+func (s *imageScan) imageEnv() []string {
+	if s.image.Config == nil {
+		return nil
+	}
+	return s.image.Config.Env
+}
+
+// findPathShadowing reports the unmanaged binaries run instead of a packaged command of the same name
+func (s *imageScan) findPathShadowing() {
+	img := shadow.Image{Owner: s.owner, Resolve: func(name string) (string, error) {
+		if _, ok := s.fs.Stat(name); !ok {
+			return "", fmt.Errorf("%v: not found", name)
+		}
+		return s.fs.Resolve(name)
+	}}
+	s.diff.PathShadowing = shadow.Find(shadow.PathDirs(s.imageEnv()), s.diff.ELFNames, img)
+	for _, f := range s.diff.PathShadowing {
+		log.Printf("%v: %v shadows %v of package %v\n", s.diff.ImageName, f.Binary, f.Shadows, f.Package)
+	}
+}
+
+// tagReachability records how the container runs each unmanaged binary
+func (s *imageScan) tagReachability() {
+	var entrypoint, cmd []string
+	if s.image.Config != nil {
+		entrypoint, cmd = s.image.Config.Entrypoint, s.image.Config.Cmd
+	}
+	a := reach.Analyzer{FS: s.fs, Path: shadow.PathDirs(s.imageEnv())}
+	tags := a.Tags(entrypoint, cmd, s.diff.ELFNames)
+	for i := range s.diff.Binaries {
+		s.diff.Binaries[i].Reachability = tags[s.diff.Binaries[i].Path]
+	}
+}
+
+// classifyProvenance records how each unmanaged binary was most likely installed
+func (s *imageScan) classifyProvenance() {
+	c := provenance.NewClassifier(s.history, s.fs, scanScope.Skipped)
+	for i := range s.diff.Binaries {
+		p := c.Classify(s.diff.Binaries[i].Path)
+		s.diff.Binaries[i].Provenance = &p
+	}
+}
+
+// applyDeclarations takes the binaries declared by the image labels out of the
+// unmanaged binaries, once inspected, and reports those not matching their declared hash
+func (s *imageScan) applyDeclarations() {
+	if s.image.Config == nil {
+		return
+	}
+	decls, errs := declare.Parse(s.image.Config.Labels)
+	for _, err := range errs {
+		log.Printf("%v: invalid binary declaration: %v\n", s.diff.ImageName, err)
+	}
+	if len(decls) == 0 {
+		return
+	}
+	declared := make(map[string]bool)
+	for i := range s.diff.Binaries {
+		b := &s.diff.Binaries[i]
+		d, mismatch := declare.Match(decls, b.Path, b.SHA256)
+		if mismatch != nil {
+			log.Printf("%v: %v does not match the sha256 declared by %v\n", s.diff.ImageName, b.Path, mismatch.Declaration)
+			s.diff.DeclarationMismatches = append(s.diff.DeclarationMismatches, *mismatch)
+		}
+		if d != nil {
+			b.Declaration = d
+			declared[b.Path] = true
+			s.diff.Declared = append(s.diff.Declared, b.Path)
+		}
+	}
+	var undeclared []string
+	for _, p := range s.diff.ELFNames {
+		if !declared[p] {
+			undeclared = append(undeclared, p)
+		}
+	}
+	s.diff.ELFNames = undeclared
+}
+
+// findBuildLeftovers reports the compilers and build tools of the image, packaged or not
+func (s *imageScan) findBuildLeftovers() {
+	s.diff.BuildLeftovers = toolchain.Find(s.fs, scanScope.Skipped, s.owner, toolchain.Signatures)
+	for _, l := range s.diff.BuildLeftovers {
+		log.Printf("%v: build tool %v left in the image, %v bytes\n", s.diff.ImageName, l.Tool, l.Size)
+	}
+}
+
+func (s *imageScan) ldsoAuditor() ldso.Auditor {
+	return ldso.Auditor{FS: s.fs, Managed: s.managed}
+}
+
+// auditLibrarySearchPaths checks the dynamic linker configuration of the image
+func (s *imageScan) auditLibrarySearchPaths() {
+	a := s.ldsoAuditor()
+	s.diff.LibraryHijacks = append(s.diff.LibraryHijacks, a.Preload()...)
+	s.diff.LibraryHijacks = append(s.diff.LibraryHijacks, a.Conf()...)
+}
+
+func (s *imageScan) classifyArtifacts() {
+	if len(s.diff.artifactCandidates) == 0 {
+		return
+	}
+	sort.Strings(s.diff.artifactCandidates)
+	var result artifact.Result
+	for _, p := range s.diff.artifactCandidates {
+		r, err := s.fs.Open(p)
+		if err != nil {
+			continue
+		}
+		result.Classify(p, r, r.Size(), enabledArtifacts)
+	}
+	s.diff.Scripts = result.Scripts
+	s.diff.JARs = result.JARs
+	s.diff.ZipApps = result.ZipApps
+}
@@ -23,11 +23,30 @@ import (
 	"github.com/docker/docker/api/types"
 	dockerClient "github.com/docker/docker/client"
 
+	"github.com/aquasecurity/binfinder/pkg/artifact"
+	"github.com/aquasecurity/binfinder/pkg/catalog"
+	"github.com/aquasecurity/binfinder/pkg/config"
 	"github.com/aquasecurity/binfinder/pkg/contract"
+	"github.com/aquasecurity/binfinder/pkg/declare"
+	"github.com/aquasecurity/binfinder/pkg/distindex"
+	"github.com/aquasecurity/binfinder/pkg/dockerfile"
+	"github.com/aquasecurity/binfinder/pkg/elfinfo"
+	"github.com/aquasecurity/binfinder/pkg/layerindex"
+	"github.com/aquasecurity/binfinder/pkg/ldso"
+	"github.com/aquasecurity/binfinder/pkg/pkgdb"
+	"github.com/aquasecurity/binfinder/pkg/pkgindex"
+	"github.com/aquasecurity/binfinder/pkg/privilege"
+	"github.com/aquasecurity/binfinder/pkg/provenance"
+	"github.com/aquasecurity/binfinder/pkg/reach"
 	"github.com/aquasecurity/binfinder/pkg/repository/popular"
 	"github.com/aquasecurity/binfinder/pkg/repository/popular/docker"
 	dtrRepo "github.com/aquasecurity/binfinder/pkg/repository/popular/dtr"
 	"github.com/aquasecurity/binfinder/pkg/repository/popular/registryV2"
+	"github.com/aquasecurity/binfinder/pkg/rules"
+	"github.com/aquasecurity/binfinder/pkg/shadow"
+	"github.com/aquasecurity/binfinder/pkg/toolchain"
+	"github.com/aquasecurity/binfinder/pkg/versions"
+	"github.com/aquasecurity/binfinder/pkg/vuln"
 )
 
 var (
@@ -37,6 +56,33 @@ var (
 	analyze       = flag.Bool("analyze", false, "run analysis on diff saved in data folder")
 	workers       = flag.Int("workers", 1, "run binfinder in parallel on multiple images")
 	enableAllTags = flag.Bool("all-tags", false, "run binfinder on all image tags")
+	artifacts     = flag.String("artifacts", "", "comma separated non-ELF artifact categories to report: scripts,jars,zipapps or all")
+	listOnly      = flag.Bool("list-only", false, "only list the unmanaged files, without the analyses exporting the image filesystem")
+
+	configFile  = flag.String("config", "", "JSON configuration file")
+	includeDirs = flag.String("include", "", "comma separated root directories to scan, replaces configured roots")
+	excludeDirs = flag.String("exclude", "", "comma separated path globs not descended into during the scan")
+	ignoreGlobs = flag.String("ignore", "", "comma separated path globs left out of the results")
+	maxFileSize = flag.Int64("max-size", 0, "skip files larger than this many bytes")
+	rulesDir    = flag.String("rules", "", "directory of JSON rule files matched against unmanaged binaries")
+
+	catalogFile  = flag.String("catalog", "", "JSON catalog of known upstream releases to label unmanaged binaries with")
+	buildCatalog = flag.String("build-catalog", "", "build the -catalog file from a directory of release artifacts")
+	osvDir       = flag.String("osv", "", "directory of OSV advisories matched against the components of unmanaged binaries")
+
+	indexDir  = flag.String("index-dir", ".binfinder", "directory of the indexes kept across scans, empty to disable them")
+	baseImage = flag.String("base", "", "image the scanned images are built from, instead of the one detected from the layers")
+	refImages = flag.String("reference-images", "", "comma separated images whose layers identify the base of the scanned images")
+	refDBDir  = flag.String("reference-db", "", "directory of <ID>-<VERSION_ID> package databases used for images whose database was removed")
+
+	keepInherited = flag.Bool("keep-inherited", false, "keep the unmanaged binaries inherited unchanged from the base image in ELFNames")
+
+	dockerfileName = flag.String("dockerfile", "", "Dockerfile whose instructions predict the unmanaged binaries of the scanned images")
+	distroIndexDir = flag.String("distro-index", "", "directory of <ID>-<VERSION_ID> distribution package indexes to suggest packages for unmanaged binaries")
+
+	probe        = flag.Bool("probe", false, "run unmanaged binaries in a sandboxed container to capture their version")
+	probeList    = flag.String("probe-args", "--version,-v,version", "comma separated arguments tried in turn by -probe")
+	probeTimeout = flag.Duration("probe-timeout", 5*time.Second, "time limit of each -probe run")
 
 	dtr      = flag.Bool("dtr", false, "use DTR API")
 	registry = flag.String("registry", "", "pulls images from registry")
@@ -47,6 +93,17 @@ var (
 	checkOSName     = `run -u root --rm --entrypoint cat %v /etc/os-release`
 	argsAllELFFiles = `run -u root --rm -v %v/%v.sh:/%v.sh --entrypoint sh %v /%v.sh`
 
+	// Filesystem snapshot
+	argsCreateContainer = `create -u root --entrypoint sh %v`
+	argsExportContainer = `export %v`
+	argsRemoveContainer = `rm -f %v`
+
+	// files considered during discovery, see config.Scope.FindArgs
+	discoveryExpression = []string{"-type", "f", "-executable"}
+	// files considered during discovery when -artifacts are reported
+	artifactsExpression = []string{"-type", "f", "(", "-executable",
+		"-o", "-name", "*.jar", "-o", "-name", "*.war", "-o", "-name", "*.ear", "-o", "-name", "*.pyz", ")"}
+
 	// CentOS
 	checkCentOSName = `run -u root --rm --entrypoint cat %v /etc/centos-release`
 
@@ -60,12 +117,174 @@ var (
 
 	imageProvider popular.ImageProvider
 
+	enabledArtifacts map[artifact.Category]bool
+	scanScope        = config.Default().Scope
+	ruleSet          *rules.Set
+	releases         *catalog.Catalog
+	vulnDB           *vuln.DB
+	probeArgs        []string
+	packageIndex     *pkgindex.Index
+	layerIndex       *layerindex.Index
+	predictions      []dockerfile.Prediction
+
 	cli contract.DockerContract
 )
 
 type Diffs struct {
 	ImageName string
 	ELFNames  []string
+	Scripts   []artifact.Script `json:",omitempty"`
+	JARs      []artifact.JAR    `json:",omitempty"`
+	ZipApps   []artifact.ZipApp `json:",omitempty"`
+	Binaries  []Binary          `json:",omitempty"`
+
+	// LibraryHijacks are library search entries pointing to locations an attacker may control
+	LibraryHijacks []ldso.Finding `json:",omitempty"`
+	// LibcMismatches are binaries built against a C library the image does not provide
+	LibcMismatches []ldso.LibcMismatch `json:",omitempty"`
+	// PathShadowing are unmanaged binaries found on PATH before a packaged command of the same name
+	PathShadowing []shadow.Finding `json:",omitempty"`
+	// Privileged are unmanaged files that are setuid, setgid or carry file capabilities
+	Privileged []privilege.Privilege `json:",omitempty"`
+	// BuildLeftovers are the compilers and build tools, managed or not, left in the image
+	BuildLeftovers []toolchain.Leftover `json:",omitempty"`
+	// UnmanagedSize adds up the size of the unmanaged binaries
+	UnmanagedSize *SizeTotals `json:",omitempty"`
+
+	// PackageDatabase is set when the package database of the image was removed
+	PackageDatabase string `json:",omitempty"`
+	// ReferenceDatabase is the -reference-db release used in place of the removed database
+	ReferenceDatabase string `json:",omitempty"`
+
+	// Layers are the layer digests of the image, used to find the images it is built from
+	Layers []string `json:",omitempty"`
+	// BuiltFrom is the indexed image with the longest layer chain the image starts with
+	BuiltFrom *layerindex.Match `json:",omitempty"`
+	// Base lists what the image changes in the unmanaged binaries of its base image
+	Base *BaseComparison `json:",omitempty"`
+
+	// Dockerfile tells which unmanaged binaries the instructions of the -dockerfile account for
+	Dockerfile *DockerfileCheck `json:",omitempty"`
+	// Remediation suggests the distribution packages shipping the unmanaged binaries
+	Remediation *Remediation `json:",omitempty"`
+
+	// Declared are the binaries the image labels declare, left out of ELFNames
+	Declared []string `json:",omitempty"`
+	// DeclarationMismatches are the binaries whose content differs from the sha256 declared by the image labels
+	DeclarationMismatches []declare.Mismatch `json:",omitempty"`
+
+	// unmanaged non-ELF files to classify into artifact categories
+	artifactCandidates []string
+	// release of the image, <ID>-<VERSION_ID>, when a -distro-index is given
+	release string
+}
+
+// SizeTotals adds up the size accounting of the unmanaged binaries of an image
+type SizeTotals struct {
+	Binaries int
+	Bytes    int64
+	// Unstripped is the number of binaries keeping their symbol table
+	Unstripped int
+	// DebugInfo is the number of binaries carrying DWARF sections
+	DebugInfo int
+	// StrippableBytes is what stripping the binaries would save
+	StrippableBytes int64
+}
+
+// add accounts for a binary
+func (t *SizeTotals) add(s elfinfo.Size) {
+	t.Binaries++
+	t.Bytes += s.Bytes
+	if !s.Stripped {
+		t.Unstripped++
+	}
+	if s.DebugInfo {
+		t.DebugInfo++
+	}
+	t.StrippableBytes += s.StrippableBytes
+}
+
+// Binary holds what was learned from the content of an unmanaged ELF file
+type Binary struct {
+	Path    string
+	SHA256  string `json:",omitempty"`
+	BuildID string `json:",omitempty"`
+	// Release is the known upstream release the binary comes from
+	Release *catalog.Match `json:",omitempty"`
+	// RelocatedFrom is the packaged file, of this or another scanned image, the binary is a copy of
+	RelocatedFrom *pkgindex.Relocation `json:",omitempty"`
+	Hardening     *elfinfo.Hardening   `json:",omitempty"`
+	// Size is the size of the binary and of its symbols and debug information
+	Size *elfinfo.Size `json:",omitempty"`
+	// Dependencies are the shared libraries the binary loads
+	Dependencies []ldso.Dependency `json:",omitempty"`
+	// Packing is set when the binary looks packed or obfuscated
+	Packing *elfinfo.Packing `json:",omitempty"`
+	// RuleHits are the rules of the -rules directory matching the binary
+	RuleHits []rules.Hit `json:",omitempty"`
+	// Components are the Go modules, Rust crates and known release the binary is built from
+	Components []vuln.Component `json:",omitempty"`
+	// Vulnerabilities are the OSV advisories affecting the components
+	Vulnerabilities []vuln.Vulnerability `json:",omitempty"`
+	// Probe is the version the binary printed when run with -probe
+	Probe *Probe `json:",omitempty"`
+	// VersionStrings are the project versions found in the strings of the binary
+	VersionStrings []versions.Candidate `json:",omitempty"`
+	// Reachability tells how the container runs the binary: entrypoint, scheduled, service, on-path or unreferenced
+	Reachability []string `json:",omitempty"`
+	// Provenance is how the binary was most likely installed, from the image history and build traces
+	Provenance *provenance.Provenance `json:",omitempty"`
+	// DistroPackages are the packages of the -distro-index shipping a command or library of the same name
+	DistroPackages []distindex.Candidate `json:",omitempty"`
+	// Declaration is the image label declaring the binary as expected
+	Declaration *declare.Declaration `json:",omitempty"`
+}
+
+// analysisMetrics are the rows of the analysis summary, in output order
+var analysisMetrics = []string{
+	"package_db_removed",
+	"unmanaged_binaries",
+	"declared_binaries",
+	"declaration_mismatches",
+	"unmanaged_bytes",
+	"privileged_binaries",
+	"path_shadowing",
+	"packed_binaries",
+	"rule_hits",
+	"known_releases",
+	"relocated_binaries",
+	"vulnerable_binaries",
+	"embedded_libraries",
+	"entrypoint_binaries",
+	"unreferenced_binaries",
+	"unexplained_binaries",
+	"distro_packaged_binaries",
+	"copied_from_stage_binaries",
+	"downloaded_binaries",
+	"built_from_source_binaries",
+	"language_toolchain_binaries",
+	"unknown_provenance_binaries",
+	"build_leftovers",
+	"build_leftover_bytes",
+	"unstripped_binaries",
+	"debug_info_binaries",
+	"strippable_bytes",
+	"hardening_checked",
+	"no_pie",
+	"no_relro",
+	"partial_relro",
+	"no_nx",
+	"no_canary",
+	"no_fortify",
+}
+
+// provenanceMetrics are the summary rows counting the binaries of each installation method
+var provenanceMetrics = map[string]string{
+	provenance.CopiedFromStage:   "copied_from_stage_binaries",
+	provenance.Downloaded:        "downloaded_binaries",
+	provenance.BuiltFromSource:   "built_from_source_binaries",
+	provenance.LanguageToolchain: "language_toolchain_binaries",
+	provenance.Unknown:           "unknown_provenance_binaries",
 }
 
 func Usage() {
@@ -78,6 +297,10 @@ $ binfinder -top [int] # to analyze top X popular of images from registry
 
 $ binfinder -images [image1:tag1,image2:tag2...] # to scan specified images
 
+$ binfinder -build-catalog [dir] -catalog [file] # to build a catalog of known upstream releases
+
+$ binfinder -dockerfile [file] # to list the Dockerfile instructions introducing unmanaged binaries
+
 $ binfinder -top 5 -registry "https://example.registry"  -user "foouser" -password "barpass" -output "bazdir" -workers=5
 
 Modifiers:
@@ -95,6 +318,53 @@ Modifiers:
         run binfinder in parallel on multiple images (default: 1)
   -all-tags [bool]
         run binfinder to get bianry difference on all tags of an docker image. (default: false)
+  -artifacts [string]
+        comma separated non-ELF artifact categories to report: scripts,jars,zipapps or all (default: none)
+  -list-only [bool]
+        only list the unmanaged binaries and -artifacts, skipping the analyses that need a docker export of the image
+        filesystem; the image is still exported to classify -artifacts candidates (default: false)
+  -config [string]
+        JSON configuration file, see README for the format
+  -include [string]
+        comma separated root directories to scan, replaces configured roots (default: "/")
+  -exclude [string]
+        comma separated path globs not descended into, added to configured ones (default: "/proc,/sys,/dev")
+  -ignore [string]
+        comma separated path globs left out of the results, added to configured ones (default: "*aquasec*")
+  -max-size [int]
+        skip files larger than this many bytes (default: no limit)
+  -rules [string]
+        directory of JSON rule files matched against unmanaged binaries, see README for the format
+  -catalog [string]
+        JSON catalog of known upstream releases to label unmanaged binaries with
+  -build-catalog [string]
+        build the -catalog file (default: "catalog.json") from a directory of <project>/<version>/<artifact> release files
+  -osv [string]
+        directory of OSV JSON advisories matched against the Go modules, Rust crates and releases of unmanaged binaries
+  -index-dir [string]
+        directory of the indexes kept across scans, empty to disable them (default: ".binfinder")
+  -base [string]
+        image the scanned images are built from, scanned first; their unmanaged binaries are compared against its
+        own to report the added, removed and changed ones (default: the scanned image whose layers they start with)
+  -reference-images [string]
+        comma separated images, pulled when missing, whose layers are indexed in -index-dir along with the ones of
+        every scanned image to identify the image each scanned image is built from
+  -reference-db [string]
+        directory of reference package databases, one <ID>-<VERSION_ID> directory (e.g. debian-11, alpine-3.14) per
+        distribution release, used for the images whose package database was removed, see README for the format
+  -dockerfile [string]
+        Dockerfile of the scanned images; its ADD <url>, COPY --from, curl | tar and wget && chmod +x instructions
+        are matched with the unmanaged binaries found, or listed when no image is scanned
+  -distro-index [string]
+        directory of distribution package indexes, one <ID>-<VERSION_ID> directory (e.g. debian-11, alpine-3.14) per
+        release holding Contents-<arch>, APKINDEX.tar.gz or filelists.xml files, used to suggest the packages
+        shipping the unmanaged binaries, see README
+  -probe [bool]
+        run each unmanaged binary without network, read-only and resource limited to capture its version (default: false)
+  -probe-args [string]
+        comma separated arguments tried in turn by -probe (default: "--version,-v,version")
+  -probe-timeout [duration]
+        time limit of each -probe run (default: 5s)
 `)
 }
 
@@ -110,12 +380,73 @@ func main() {
 			log.Fatalf("error creating output directory to save diffs: %v", err)
 		}
 	}
+	var err error
+	if enabledArtifacts, err = artifact.ParseCategories(*artifacts); err != nil {
+		log.Printf("invalid -artifacts value: %v", err)
+		return
+	}
+	if scanScope, err = loadScope(); err != nil {
+		log.Printf("error loading configuration: %v", err)
+		return
+	}
+	probeArgs = splitList(*probeList)
+	if *rulesDir != "" {
+		if ruleSet, err = rules.Load(*rulesDir); err != nil {
+			log.Printf("error loading rules: %v", err)
+			return
+		}
+	}
+	if *buildCatalog != "" {
+		name := *catalogFile
+		if name == "" {
+			name = "catalog.json"
+		}
+		log.Printf("building catalog of %v and saving to: %v", *buildCatalog, name)
+		if err = exportCatalog(*buildCatalog, name); err != nil {
+			log.Printf("error building catalog: %v", err)
+		}
+		return
+	}
+	if *catalogFile != "" {
+		if releases, err = catalog.Load(*catalogFile); err != nil {
+			log.Printf("error loading catalog: %v", err)
+			return
+		}
+	}
+	if *osvDir != "" {
+		if vulnDB, err = vuln.Load(*osvDir); err != nil {
+			log.Printf("error loading OSV advisories: %v", err)
+			return
+		}
+		log.Printf("loaded %v OSV advisories", vulnDB.Size())
+	}
+	if *dockerfileName != "" && !*analyze {
+		if predictions, err = loadPredictions(*dockerfileName); err != nil {
+			log.Printf("error loading Dockerfile: %v", err)
+			return
+		}
+		if *images == "" && *topN == 0 {
+			printPredictions(*dockerfileName, predictions)
+			return
+		}
+	}
+	if *indexDir != "" && !*analyze {
+		if packageIndex, err = pkgindex.Open(filepath.Join(*indexDir, "packages")); err != nil {
+			log.Printf("error loading package index: %v", err)
+			return
+		}
+		if layerIndex, err = layerindex.Open(filepath.Join(*indexDir, "layers")); err != nil {
+			log.Printf("error loading layer index: %v", err)
+			return
+		}
+	}
 	if *analyze {
-		log.Printf("analyzing results and saving to: analysis.csv")
+		log.Printf("analyzing results and saving to: analysis.csv, %v, %v, %v and %v", summaryFileName("analysis.csv"),
+			reportFileName("analysis.csv", "build-leftovers"), reportFileName("analysis.csv", "images"),
+			reportFileName("analysis.csv", "base-images"))
 		exportAnalysis("analysis.csv")
 		return
 	}
-	var err error
 	cli, err = dockerClient.NewEnvClient()
 	if err != nil {
 		log.Printf("unable to initialize docker client: %v", err)
@@ -150,47 +481,90 @@ func main() {
 		return
 	}
 
-	concurrency := make(chan bool, *workers)
-	wg := &sync.WaitGroup{}
+	if *refImages != "" {
+		if layerIndex == nil {
+			log.Printf("-reference-images needs an -index-dir")
+			return
+		}
+		indexReferenceImages(splitList(*refImages))
+	}
+	if *baseImage != "" {
+		// the images are compared against the diff of the base image, written first
+		if _, err := os.Stat(diffFileName(*baseImage)); err != nil {
+			scanImage(*baseImage, func(fetch func(string)) { fetch(*baseImage) })
+		}
+	}
+	var pending []string
 	for _, img := range strings.Split(*images, ",") {
-		_, err := os.Stat(fmt.Sprintf("%v/%v", *outputDir, strings.ReplaceAll(img, "/", "-")+"-diff.json"))
+		_, err := os.Stat(diffFileName(img))
 		if err == nil || img == "busybox" {
 			log.Printf("skipping img: %v to parse diff, already present", img)
 			continue
 		}
-		osName, err := getOS(img)
-		if err != nil {
-			log.Printf("unable to get OS info skipping img: %v", img)
-			continue
+		pending = append(pending, img)
+	}
+	waves := [][]string{pending}
+	if *workers > 1 && *baseImage == "" && layerIndex != nil {
+		// the images built on other scanned images are compared against their
+		// diff, scanned in a later wave than them
+		waves = scanWaves(pending, imageLayers(pending))
+	}
+	concurrency := make(chan bool, *workers)
+	wg := &sync.WaitGroup{}
+	for _, wave := range waves {
+		for _, img := range wave {
+			scanImage(img, func(fetch func(string)) {
+				concurrency <- true
+				wg.Add(1)
+				go func(img string) {
+					defer wg.Done()
+					fetch(img)
+					<-concurrency
+				}(img)
+			})
+		}
+		wg.Wait()
+	}
+}
+
+func exportCatalog(dir, outputFile string) error {
+	c, err := catalog.Build(dir)
+	if err != nil {
+		return err
+	}
+	log.Printf("catalog holds %v release binaries", len(c.Entries))
+	return c.Save(outputFile)
+}
+
+// loadScope merges the scan scope of the configuration file with the CLI flags
+func loadScope() (config.Scope, error) {
+	c := config.Default()
+	if *configFile != "" {
+		var err error
+		if c, err = config.Load(*configFile); err != nil {
+			return c.Scope, err
+		}
+	}
+	s := c.Scope
+	if *includeDirs != "" {
+		s.Include = splitList(*includeDirs)
+	}
+	s.Exclude = append(s.Exclude, splitList(*excludeDirs)...)
+	s.Ignore = append(s.Ignore, splitList(*ignoreGlobs)...)
+	if *maxFileSize > 0 {
+		s.MaxFileSize = *maxFileSize
+	}
+	return s.Compile(), nil
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
 		}
-		osName = strings.ToLower(osName)
-		if strings.Contains(osName, "alpine") {
-			concurrency <- true
-			wg.Add(1)
-			go func(img string) {
-				defer wg.Done()
-				fetchAlpineDiff(img)
-				<-concurrency
-			}(img)
-		} else if strings.Contains(osName, "ubuntu") || strings.Contains(osName, "debian") {
-			concurrency <- true
-			wg.Add(1)
-			go func(img string) {
-				defer wg.Done()
-				fetchUbuntuDiff(img)
-				<-concurrency
-			}(img)
-		} else if strings.Contains(osName, "centos") || strings.Contains(osName, "linux") {
-			concurrency <- true
-			wg.Add(1)
-			go func(img string) {
-				defer wg.Done()
-				fetchCentOSDiff(img)
-				<-concurrency
-			}(img)
-		}
-	}
-	wg.Wait()
+	}
+	return items
 }
 
 func isDockerDaemonRunning() bool {
@@ -203,6 +577,11 @@ func isDockerDaemonRunning() bool {
 
 func exportAnalysis(outputFile string) {
 	diffFileCount := make(map[string]int64)
+	privilegedCount := make(map[string]int64)
+	summary := make(map[string]int64)
+	var leftovers []buildLeftover
+	var sizes []imageSize
+	bases := make(map[string]*baseGroup)
 	filepath.Walk(*outputDir, func(path string, info os.FileInfo, err error) error {
 		if info == nil {
 			return nil
@@ -228,6 +607,17 @@ func exportAnalysis(outputFile string) {
 				}
 				diffFileCount[e] = diffFileCount[e] + 1
 			}
+			for _, p := range d.Privileged {
+				privilegedCount[p.Path] = privilegedCount[p.Path] + 1
+			}
+			for _, l := range d.BuildLeftovers {
+				leftovers = append(leftovers, buildLeftover{image: d.ImageName, leftover: l})
+			}
+			if d.UnmanagedSize != nil {
+				sizes = append(sizes, imageSize{image: d.ImageName, totals: *d.UnmanagedSize})
+			}
+			groupByBase(d, bases)
+			summarizeDiff(d, summary)
 		}
 		return nil
 	})
@@ -253,12 +643,197 @@ func exportAnalysis(outputFile string) {
 	}
 	w := csv.NewWriter(f)
 	defer w.Flush()
-	_ = w.Write([]string{"binary", "count"})
+	_ = w.Write([]string{"binary", "count", "privileged"})
 	for _, c := range counts {
-		if err = w.Write([]string{c.name, fmt.Sprintf("%v", c.count)}); err != nil {
+		if err = w.Write([]string{c.name, fmt.Sprintf("%v", c.count), fmt.Sprintf("%v", privilegedCount[c.name])}); err != nil {
 			log.Printf("error writing row to CSV analysis, got error: %v", err)
 		}
 	}
+	exportSummary(summaryFileName(outputFile), summary)
+	exportBuildLeftovers(reportFileName(outputFile, "build-leftovers"), leftovers)
+	exportImageSizes(reportFileName(outputFile, "images"), sizes)
+	exportBaseGroups(reportFileName(outputFile, "base-images"), bases)
+}
+
+// summarizeDiff adds the unmanaged binaries of a diff to the analysis summary
+func summarizeDiff(d Diffs, summary map[string]int64) {
+	if d.PackageDatabase != "" {
+		summary["package_db_removed"]++
+	}
+	inherited := inheritedOnly(d)
+	summary["unmanaged_binaries"] += int64(len(d.ELFNames))
+	summary["declared_binaries"] += int64(len(d.Declared))
+	summary["declaration_mismatches"] += int64(len(d.DeclarationMismatches))
+	for _, p := range d.Privileged {
+		if !inherited[p.Path] {
+			summary["privileged_binaries"]++
+		}
+	}
+	summary["path_shadowing"] += int64(len(d.PathShadowing))
+	if t := d.UnmanagedSize; t != nil {
+		summary["unmanaged_bytes"] += t.Bytes
+		summary["unstripped_binaries"] += int64(t.Unstripped)
+		summary["debug_info_binaries"] += int64(t.DebugInfo)
+		summary["strippable_bytes"] += t.StrippableBytes
+	}
+	if d.Dockerfile != nil {
+		summary["unexplained_binaries"] += int64(len(d.Dockerfile.Unexplained))
+	}
+	summary["build_leftovers"] += int64(len(d.BuildLeftovers))
+	for _, l := range d.BuildLeftovers {
+		summary["build_leftover_bytes"] += l.Size
+	}
+	for _, b := range d.Binaries {
+		if inherited[b.Path] {
+			continue
+		}
+		if b.Packing != nil {
+			summary["packed_binaries"]++
+		}
+		if len(b.RuleHits) > 0 {
+			summary["rule_hits"]++
+		}
+		if b.Release != nil {
+			summary["known_releases"]++
+		}
+		if b.RelocatedFrom != nil {
+			summary["relocated_binaries"]++
+		}
+		if len(b.Vulnerabilities) > 0 {
+			summary["vulnerable_binaries"]++
+		}
+		for _, v := range b.VersionStrings {
+			if v.Library {
+				summary["embedded_libraries"]++
+				break
+			}
+		}
+		for _, r := range b.Reachability {
+			switch r {
+			case reach.Entrypoint:
+				summary["entrypoint_binaries"]++
+			case reach.Unreferenced:
+				summary["unreferenced_binaries"]++
+			}
+		}
+		if b.Provenance != nil {
+			summary[provenanceMetrics[b.Provenance.Method]]++
+		}
+		if len(b.DistroPackages) > 0 {
+			summary["distro_packaged_binaries"]++
+		}
+		h := b.Hardening
+		if h == nil {
+			continue
+		}
+		summary["hardening_checked"]++
+		if !h.PIE {
+			summary["no_pie"]++
+		}
+		switch h.RELRO {
+		case elfinfo.RelroNone:
+			summary["no_relro"]++
+		case elfinfo.RelroPartial:
+			summary["partial_relro"]++
+		}
+		if !h.NX {
+			summary["no_nx"]++
+		}
+		if !h.Canary {
+			summary["no_canary"]++
+		}
+		if !h.Fortify {
+			summary["no_fortify"]++
+		}
+	}
+}
+
+// summaryFileName derives the summary file name from the analysis file name,
+// analysis.csv is summarized in analysis-summary.csv
+func summaryFileName(outputFile string) string {
+	return reportFileName(outputFile, "summary")
+}
+
+// reportFileName derives the name of another analysis report from the
+// analysis file name, e.g. analysis-build-leftovers.csv
+func reportFileName(outputFile string, report string) string {
+	ext := filepath.Ext(outputFile)
+	return strings.TrimSuffix(outputFile, ext) + "-" + report + ext
+}
+
+// imageSize is the size accounting of the unmanaged binaries of an analyzed image
+type imageSize struct {
+	image  string
+	totals SizeTotals
+}
+
+// exportImageSizes ranks the images by the bytes of their unmanaged binaries
+func exportImageSizes(outputFile string, sizes []imageSize) {
+	sort.SliceStable(sizes, func(i, j int) bool {
+		return sizes[i].totals.Bytes > sizes[j].totals.Bytes
+	})
+	f, err := os.Create(outputFile)
+	if err != nil {
+		log.Printf("error exporting image sizes, got error: %v", err)
+		return
+	}
+	defer f.Close()
+	w := csv.NewWriter(f)
+	defer w.Flush()
+	_ = w.Write([]string{"image", "binaries", "bytes", "unstripped", "debug_info", "strippable_bytes"})
+	for _, s := range sizes {
+		t := s.totals
+		if err = w.Write([]string{s.image, fmt.Sprintf("%v", t.Binaries), fmt.Sprintf("%v", t.Bytes),
+			fmt.Sprintf("%v", t.Unstripped), fmt.Sprintf("%v", t.DebugInfo), fmt.Sprintf("%v", t.StrippableBytes)}); err != nil {
+			log.Printf("error writing row to CSV image sizes, got error: %v", err)
+		}
+	}
+}
+
+// buildLeftover is a build tool left in an analyzed image
+type buildLeftover struct {
+	image    string
+	leftover toolchain.Leftover
+}
+
+// exportBuildLeftovers writes the build tools of every image, largest first,
+// to show the images that would gain the most from a multi-stage build
+func exportBuildLeftovers(outputFile string, leftovers []buildLeftover) {
+	sort.SliceStable(leftovers, func(i, j int) bool {
+		return leftovers[i].leftover.Size > leftovers[j].leftover.Size
+	})
+	f, err := os.Create(outputFile)
+	if err != nil {
+		log.Printf("error exporting build leftovers, got error: %v", err)
+		return
+	}
+	defer f.Close()
+	w := csv.NewWriter(f)
+	defer w.Flush()
+	_ = w.Write([]string{"image", "tool", "kind", "managed", "bytes", "commands"})
+	for _, l := range leftovers {
+		if err = w.Write([]string{l.image, l.leftover.Tool, l.leftover.Kind, fmt.Sprintf("%v", l.leftover.Managed),
+			fmt.Sprintf("%v", l.leftover.Size), strings.Join(l.leftover.Commands, " ")}); err != nil {
+			log.Printf("error writing row to CSV build leftovers, got error: %v", err)
+		}
+	}
+}
+
+func exportSummary(outputFile string, summary map[string]int64) {
+	f, err := os.Create(outputFile)
+	if err != nil {
+		log.Printf("error exporting analysis summary, got error: %v", err)
+		return
+	}
+	defer f.Close()
+	w := csv.NewWriter(f)
+	defer w.Flush()
+	_ = w.Write([]string{"metric", "count"})
+	for _, m := range analysisMetrics {
+		if err = w.Write([]string{m, fmt.Sprintf("%v", summary[m])}); err != nil {
+			log.Printf("error writing row to CSV analysis summary, got error: %v", err)
+		}
+	}
 }
 
 func pullImage(imageName string) error {
@@ -327,15 +902,15 @@ func getPackages(osName string, imageName string, command ...string) ([]byte, er
 	return out, nil
 }
 
-func findBins(pkgELFFiles map[string]bool, osName string, imageName string, diffJson *Diffs, command ...string) int {
-	pkgELFFiles["/usr/bin/file"] = true
+func findBins(pkgELFFiles map[string]string, osName string, imageName string, diffJson *Diffs, command ...string) int {
+	pkgELFFiles["/usr/bin/file"] = "file"
 	// binaries from findutils package
 	// source: https://pkgs.alpinelinux.org/contents?branch=edge&name=findutils&arch=x86&repo=main
-	pkgELFFiles["/usr/bin/find"] = true
-	pkgELFFiles["/usr/bin/xargs"] = true
-	pkgELFFiles["/usr/bin/updatedb"] = true
-	pkgELFFiles["/usr/bin/locate"] = true
-	pkgELFFiles["/usr/libexec/frcode"] = true
+	pkgELFFiles["/usr/bin/find"] = "findutils"
+	pkgELFFiles["/usr/bin/xargs"] = "findutils"
+	pkgELFFiles["/usr/bin/updatedb"] = "findutils"
+	pkgELFFiles["/usr/bin/locate"] = "findutils"
+	pkgELFFiles["/usr/libexec/frcode"] = "findutils"
 
 	out, err := exec.Command("docker", command...).Output()
 	if err != nil {
@@ -346,16 +921,22 @@ func findBins(pkgELFFiles map[string]bool, osName string, imageName string, diff
 	for _, f := range strings.Split(string(out), "\n") {
 		parts := strings.Split(f, ":")
 		if len(parts) > 1 {
-			if strings.HasPrefix(strings.TrimSpace(parts[1]), "ELF") &&
-				!strings.HasSuffix(parts[0], ".so") &&
-				!strings.Contains(parts[0], ".so.") &&
-				!strings.Contains(parts[0], "aquasec") {
-				f = strings.TrimSpace(parts[0])
-				if f != "" {
-					count++
-					if _, ok := pkgELFFiles[f]; !ok {
-						diffJson.ELFNames = append(diffJson.ELFNames, strings.TrimSpace(f))
-					}
+			if strings.HasSuffix(parts[0], ".so") ||
+				strings.Contains(parts[0], ".so.") {
+				continue
+			}
+			f = strings.TrimSpace(parts[0])
+			if f == "" || scanScope.Ignored(f) {
+				continue
+			}
+			if strings.HasPrefix(strings.TrimSpace(parts[1]), "ELF") {
+				count++
+				if _, ok := pkgELFFiles[f]; !ok {
+					diffJson.ELFNames = append(diffJson.ELFNames, f)
+				}
+			} else if len(enabledArtifacts) > 0 {
+				if _, ok := pkgELFFiles[f]; !ok {
+					diffJson.artifactCandidates = append(diffJson.artifactCandidates, f)
 				}
 			}
 		}
@@ -363,28 +944,101 @@ func findBins(pkgELFFiles map[string]bool, osName string, imageName string, diff
 	return count
 }
 
+// discoveryArgs returns the arguments of the <os>.sh discovery scripts, which
+// only report the non-ELF files too when -artifacts are enabled
+func discoveryArgs() []string {
+	if len(enabledArtifacts) == 0 {
+		return append([]string{"elf"}, scanScope.FindArgs(discoveryExpression...)...)
+	}
+	return append([]string{"all"}, scanScope.FindArgs(artifactsExpression...)...)
+}
+
+// scanImage runs the diff function matching the OS of the image through run
+func scanImage(img string, run func(fetch func(string))) {
+	osName, err := getOS(img)
+	if err != nil {
+		log.Printf("unable to get OS info skipping img: %v", img)
+		return
+	}
+	osName = strings.ToLower(osName)
+	if strings.Contains(osName, "alpine") {
+		run(fetchAlpineDiff)
+	} else if strings.Contains(osName, "ubuntu") || strings.Contains(osName, "debian") {
+		run(fetchUbuntuDiff)
+	} else if strings.Contains(osName, "centos") || strings.Contains(osName, "linux") {
+		run(fetchCentOSDiff)
+	}
+}
+
 func generateDiffFile(diffJson Diffs, osName string, imageName string) {
 	sort.Slice(diffJson.ELFNames, func(i, j int) bool {
 		return strings.Compare(diffJson.ELFNames[i], diffJson.ELFNames[j]) <= 0
 	})
+	diffJson.Base = compareWithBase(diffJson)
+	if !*keepInherited {
+		dropInherited(&diffJson)
+	}
+	suggestPackages(&diffJson)
+	diffJson.Dockerfile = checkDockerfile(diffJson)
 	content, err := json.MarshalIndent(diffJson, "", " ")
 	if err != nil {
 		log.Printf("%v: %s, error marshalling diff: %v\n", osName, imageName, err)
 		return
 	}
-	file, err := os.Create(fmt.Sprintf("%v/%v", *outputDir, strings.ReplaceAll(imageName, "/", "-")+"-diff.json"))
-	if err != nil {
-		log.Printf("%v: %s, error creating diff file: %v\n", osName, imageName, err)
-		return
-	}
-	defer file.Close()
-	_, err = io.Copy(file, bytes.NewReader(content))
-	if err != nil {
+	if err = writeDiffFile(diffFileName(imageName), content); err != nil {
 		log.Printf("%v: %s, error writing diff file: %v\n", osName, imageName, err)
 		return
 	}
 	fmt.Printf("%v: found %v binaries installed not through a package manager\n", imageName,
 		len(diffJson.ELFNames))
+	if len(diffJson.Declared) > 0 || len(diffJson.DeclarationMismatches) > 0 {
+		fmt.Printf("%v: %v binaries declared by the image labels, %v not matching their declared sha256\n", imageName,
+			len(diffJson.Declared), len(diffJson.DeclarationMismatches))
+	}
+	if t := diffJson.UnmanagedSize; t != nil {
+		fmt.Printf("%v: unmanaged binaries take %v bytes, %v unstripped, %v with debug info, %v bytes strippable\n",
+			imageName, t.Bytes, t.Unstripped, t.DebugInfo, t.StrippableBytes)
+	}
+	if b := diffJson.BuiltFrom; b != nil {
+		fmt.Printf("%v: built from %v, %v shared layers\n", imageName, b.Image, b.Layers)
+	}
+	if b := diffJson.Base; b != nil {
+		fmt.Printf("%v: on top of base image %v, %v binaries added, %v removed, %v changed\n", imageName, b.Image,
+			len(b.Added), len(b.Removed), len(b.Changed))
+	}
+	if r := diffJson.Remediation; r != nil {
+		fmt.Printf("%v: %v packages of %v ship unmanaged binaries: %v\n", imageName, len(r.Packages), r.Release, r.Snippet)
+	}
+	if c := diffJson.Dockerfile; c != nil {
+		fmt.Printf("%v: %v binaries not accounted for by %v\n", imageName, len(c.Unexplained), c.File)
+	}
+}
+
+// writeDiffFile writes the diff file through a temporary file renamed in place,
+// so that the scans comparing with it never read it partially written
+func writeDiffFile(name string, content []byte) error {
+	file, err := ioutil.TempFile(filepath.Dir(name), ".diff-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if _, err = io.Copy(file, bytes.NewReader(content)); err != nil {
+		file.Close()
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(file.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), name)
+}
+
+// packageFiles are the files installed by a package
+type packageFiles struct {
+	name  string
+	files []string
 }
 
 func fetchAlpineDiff(imageName string) {
@@ -392,11 +1046,11 @@ func fetchAlpineDiff(imageName string) {
 	diffJson := Diffs{ImageName: imageName}
 	allPackages := make(map[string]bool)
 
+	// without a package database every package file list stays empty
 	out, err := getPackages("alpine", imageName, strings.Split(fmt.Sprintf(argsParseAPKFile, imageName), " ")...)
-	if err != nil {
+	if err != nil && !packageDBMissing(err) {
 		return
 	}
-
 	for _, f := range strings.Split(string(out), "\n") {
 		if strings.TrimSpace(f) != "" {
 			if len(f) < 2 {
@@ -407,11 +1061,11 @@ func fetchAlpineDiff(imageName string) {
 			}
 		}
 	}
-	pkgELFFiles := make(map[string]bool)
-	jobChan := make(chan []string, len(allPackages))
+	pkgELFFiles := make(map[string]string)
+	jobChan := make(chan packageFiles, len(allPackages))
 	for p := range allPackages {
-		go func(jobChan chan<- []string, imageName, pkgName string) {
-			var result []string
+		go func(jobChan chan<- packageFiles, imageName, pkgName string) {
+			result := packageFiles{name: pkgName}
 			defer func() {
 				jobChan <- result
 			}()
@@ -426,27 +1080,40 @@ func fetchAlpineDiff(imageName string) {
 					if !strings.HasPrefix(f, "/") {
 						f = "/" + f
 					}
-					result = append(result, f)
+					result.files = append(result.files, f)
 				}
 			}
 		}(jobChan, imageName, p)
 	}
 	for jobCount := 0; jobCount < len(allPackages); jobCount++ {
 		select {
-		case files := <-jobChan:
-			for _, f := range files {
-				pkgELFFiles[f] = true
+		case result := <-jobChan:
+			for _, f := range result.files {
+				pkgELFFiles[f] = result.name
 			}
 		}
 	}
+	if len(pkgELFFiles) == 0 && len(allPackages) > 0 {
+		// images without apk keep the database, the file lists are read from it
+		log.Printf("%v: alpine OS, apk info failed, reading package files from the database\n", imageName)
+		pkgELFFiles = pkgdb.ParseAPKInstalled(out)
+	}
 	fmt.Printf("%v: found %v packages took %v\n", imageName, len(pkgELFFiles), time.Since(now))
+	if len(pkgELFFiles) == 0 {
+		if pkgELFFiles = referencePackageFiles("alpine", imageName, &diffJson); pkgELFFiles == nil {
+			generateDiffFile(diffJson, "alpine", imageName)
+			return
+		}
+	}
 
 	now = time.Now()
 	currDir, _ := os.Getwd()
 	cmd := strings.Split(fmt.Sprintf(argsAllELFFiles, currDir, "alpine", "alpine", imageName, "alpine"), " ")
+	cmd = append(cmd, discoveryArgs()...)
 	count := findBins(pkgELFFiles, "alpine", imageName, &diffJson, cmd...)
 
 	fmt.Printf("%v: found %v binaries took %v\n", imageName, count, time.Since(now))
+	inspectImage(pkgELFFiles, "alpine", imageName, &diffJson)
 	generateDiffFile(diffJson, "alpine", imageName)
 
 }
@@ -455,68 +1122,74 @@ func fetchUbuntuDiff(imageName string) {
 	now := time.Now()
 	diffJson := Diffs{ImageName: imageName}
 	fileLists := make(map[string]bool)
-	pkgELFFiles := make(map[string]bool)
+	pkgELFFiles := make(map[string]string)
 
 	out, err := getPackages("ubuntu", imageName, strings.Split(fmt.Sprintf(listArgs, imageName), " ")...)
-	if err != nil {
+	if err != nil && !packageDBMissing(err) {
 		return
 	}
-
 	for _, f := range strings.Split(string(out), "\n") {
 		if strings.TrimSpace(f) != "" && strings.HasSuffix(f, ".list") {
 			fileLists[f] = true
 		}
 	}
 	for f := range fileLists {
-		out, err = exec.Command("docker",
+		out, err := exec.Command("docker",
 			strings.Split(fmt.Sprintf(parseFile, imageName, f), " ")...).Output()
 		if err != nil {
 			log.Printf("%v: ubuntu, error listing pkg bin files: %v\n", imageName, err)
 			return
 		}
-		for _, content := range strings.Split(string(out), "\n") {
-			if strings.TrimSpace(content) != "" {
-				pkgELFFiles[content] = true
-			}
+		pkgName := pkgdb.DpkgPackage(f)
+		for content := range pkgdb.ParseDpkgList(out) {
+			pkgELFFiles[content] = pkgName
 		}
 	}
 	fmt.Printf("%v: found %v packages took %v\n", imageName, len(pkgELFFiles), time.Since(now))
+	if len(pkgELFFiles) == 0 {
+		if pkgELFFiles = referencePackageFiles("ubuntu", imageName, &diffJson); pkgELFFiles == nil {
+			generateDiffFile(diffJson, "ubuntu", imageName)
+			return
+		}
+	}
 
 	now = time.Now()
 	currDir, _ := os.Getwd()
 	cmd := strings.Split(fmt.Sprintf(argsAllELFFiles, currDir, "ubuntu", "ubuntu", imageName, "ubuntu"), " ")
+	cmd = append(cmd, discoveryArgs()...)
 	count := findBins(pkgELFFiles, "ubuntu", imageName, &diffJson, cmd...)
 
 	fmt.Printf("%v: found %v binaries took %v\n", imageName, count, time.Since(now))
+	inspectImage(pkgELFFiles, "ubuntu", imageName, &diffJson)
 	generateDiffFile(diffJson, "ubuntu", imageName)
 }
 
 func fetchCentOSDiff(imageName string) {
 	now := time.Now()
 	diffJson := Diffs{ImageName: imageName}
-	pkgELFFiles := make(map[string]bool)
 	currDir, _ := os.Getwd()
 
 	out, err := getPackages("centOS", imageName, strings.Split(fmt.Sprintf(argsAllELFFiles, currDir, "centos_get_all_pkg", "centos_get_all_pkg", imageName, "centos_get_all_pkg"), " ")...)
-	if err != nil {
+	if err != nil && !packageDBMissing(err) {
 		return
 	}
 
-	for _, f := range strings.Split(string(out), "\n") {
-		f = strings.TrimSpace(f)
-		if f != "" && !strings.HasSuffix(f, "contains:") {
-			if !strings.HasPrefix(f, "/") {
-				f = "/" + f
-			}
-			pkgELFFiles[f] = true
+	// centos_get_all_pkg.sh prints a "<package>\t<file>" line per packaged file
+	pkgELFFiles := pkgdb.ParseTSV(out)
+	fmt.Printf("%v: found %v packages took %v\n", imageName, len(pkgELFFiles), time.Since(now))
+	if len(pkgELFFiles) == 0 {
+		if pkgELFFiles = referencePackageFiles("centOS", imageName, &diffJson); pkgELFFiles == nil {
+			generateDiffFile(diffJson, "centOS", imageName)
+			return
 		}
 	}
-	fmt.Printf("%v: found %v packages took %v\n", imageName, len(pkgELFFiles), time.Since(now))
 
 	now = time.Now()
 	cmd := strings.Split(fmt.Sprintf(argsAllELFFiles, currDir, "centos", "centos", imageName, "centos"), " ")
+	cmd = append(cmd, discoveryArgs()...)
 	count := findBins(pkgELFFiles, "centOS", imageName, &diffJson, cmd...)
 
 	fmt.Printf("%v: found %v binaries took %v\n", imageName, count, time.Since(now))
+	inspectImage(pkgELFFiles, "centOS", imageName, &diffJson)
 	generateDiffFile(diffJson, "centOS", imageName)
 }
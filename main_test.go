@@ -1,9 +1,14 @@
 package main
 
 import (
+	"bytes"
+	"compress/zlib"
+	"debug/elf"
+	"encoding/binary"
 	"errors"
 	"io/ioutil"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
 	"testing"
@@ -17,7 +22,15 @@ import (
 
 	"github.com/golang/mock/gomock"
 
+	"github.com/aquasecurity/binfinder/pkg/artifact"
+	"github.com/aquasecurity/binfinder/pkg/catalog"
+	"github.com/aquasecurity/binfinder/pkg/config"
 	"github.com/aquasecurity/binfinder/pkg/contract"
+	"github.com/aquasecurity/binfinder/pkg/elfinfo"
+	"github.com/aquasecurity/binfinder/pkg/elfinfo/elftest"
+	"github.com/aquasecurity/binfinder/pkg/layerindex"
+	"github.com/aquasecurity/binfinder/pkg/privilege"
+	"github.com/aquasecurity/binfinder/pkg/vuln"
 )
 
 func TestGetOS(t *testing.T) {
@@ -104,37 +117,37 @@ func TestExportAnalysis(t *testing.T) {
 		{
 			name:      "happy path, good data only",
 			goldenDir: "goldens/good-data",
-			expectedOutput: `binary,count
-/usr/bin/grep,1
-/usr/bin/rpm,1
-/usr/bin/sed,1
-/usr/sbin/chkconfig,1
-/usr/sbin/install-info,1
-/usr/sbin/ldconfig,1
+			expectedOutput: `binary,count,privileged
+/usr/bin/grep,1,0
+/usr/bin/rpm,1,0
+/usr/bin/sed,1,0
+/usr/sbin/chkconfig,1,0
+/usr/sbin/install-info,1,0
+/usr/sbin/ldconfig,1,0
 `,
 		},
 		{
 			name:      "happy path, good and bad data",
 			goldenDir: "goldens/good-and-bad-data",
-			expectedOutput: `binary,count
-/usr/bin/sed,2
-/usr/sbin/ldconfig,1
-/usr/sbin/install-info,1
-/usr/sbin/chkconfig,1
-/usr/bin/rpm,1
-/usr/bin/grep,1
+			expectedOutput: `binary,count,privileged
+/usr/bin/sed,2,0
+/usr/sbin/ldconfig,1,0
+/usr/sbin/install-info,1,0
+/usr/sbin/chkconfig,1,0
+/usr/bin/rpm,1,0
+/usr/bin/grep,1,0
 `,
 		},
 		{
 			name:      "happy path, empty valid dir with no files",
 			goldenDir: "goldens/empty-data",
-			expectedOutput: `binary,count
+			expectedOutput: `binary,count,privileged
 `,
 		},
 		{
 			name:      "sad path, invalid data dir",
 			goldenDir: "foobarbaz",
-			expectedOutput: `binary,count
+			expectedOutput: `binary,count,privileged
 `,
 		},
 	}
@@ -145,6 +158,10 @@ func TestExportAnalysis(t *testing.T) {
 			d, _ := ioutil.TempFile("", "TestExportAnalysis-*")
 			defer func() {
 				_ = os.RemoveAll(d.Name())
+				_ = os.RemoveAll(summaryFileName(d.Name()))
+				_ = os.RemoveAll(reportFileName(d.Name(), "build-leftovers"))
+				_ = os.RemoveAll(reportFileName(d.Name(), "images"))
+				_ = os.RemoveAll(reportFileName(d.Name(), "base-images"))
 			}()
 			exportAnalysis(d.Name())
 			b, err := ioutil.ReadFile(d.Name())
@@ -158,6 +175,82 @@ func TestExportAnalysis(t *testing.T) {
 	}
 }
 
+func TestExportAnalysis_Summary(t *testing.T) {
+	goldenDir := "goldens/hardening-data"
+	outputDir = &goldenDir
+	d, _ := ioutil.TempDir("", "TestExportAnalysis_Summary-*")
+	defer func() {
+		_ = os.RemoveAll(d)
+	}()
+
+	analysisFile := filepath.Join(d, "analysis.csv")
+	exportAnalysis(analysisFile)
+	b, err := ioutil.ReadFile(analysisFile)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), "/usr/local/bin/gosu,1,1\n")
+	assert.Contains(t, string(b), "/usr/local/bin/node,1,0\n")
+
+	b, err = ioutil.ReadFile(filepath.Join(d, "analysis-summary.csv"))
+	require.NoError(t, err)
+	assert.Equal(t, `metric,count
+package_db_removed,1
+unmanaged_binaries,5
+declared_binaries,1
+declaration_mismatches,1
+unmanaged_bytes,92123368
+privileged_binaries,1
+path_shadowing,1
+packed_binaries,1
+rule_hits,1
+known_releases,1
+relocated_binaries,1
+vulnerable_binaries,1
+embedded_libraries,1
+entrypoint_binaries,1
+unreferenced_binaries,1
+unexplained_binaries,1
+distro_packaged_binaries,1
+copied_from_stage_binaries,0
+downloaded_binaries,0
+built_from_source_binaries,1
+language_toolchain_binaries,0
+unknown_provenance_binaries,1
+build_leftovers,2
+build_leftover_bytes,48450288
+unstripped_binaries,2
+debug_info_binaries,1
+strippable_bytes,5636032
+hardening_checked,3
+no_pie,2
+no_relro,1
+partial_relro,1
+no_nx,0
+no_canary,1
+no_fortify,2
+`, string(b))
+
+	b, err = ioutil.ReadFile(filepath.Join(d, "analysis-build-leftovers.csv"))
+	require.NoError(t, err)
+	assert.Equal(t, `image,tool,kind,managed,bytes,commands
+node,gcc,compiler,true,48213504,/usr/bin/gcc /usr/bin/gcc-10
+node,make,build-tool,true,236784,/usr/bin/make
+`, string(b))
+
+	b, err = ioutil.ReadFile(filepath.Join(d, "analysis-images.csv"))
+	require.NoError(t, err)
+	assert.Equal(t, `image,binaries,bytes,unstripped,debug_info,strippable_bytes
+node,3,80273304,1,0,4112456
+redis,2,11850064,1,1,1523576
+`, string(b))
+
+	b, err = ioutil.ReadFile(filepath.Join(d, "analysis-base-images.csv"))
+	require.NoError(t, err)
+	assert.Equal(t, `base_image,images,unmanaged_binaries,unmanaged_bytes,images_adding_binaries
+unknown,2,3,80273304,0
+debian:bullseye-slim,1,2,11850064,0
+`, string(b))
+}
+
 func Test_isDockerDaemonRunning(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -248,3 +341,330 @@ func Test_fetchCentOSDiff(t *testing.T) {
  ]
 }`, string(b))
 }
+
+func TestLoadScope(t *testing.T) {
+	d, _ := ioutil.TempDir("", "TestLoadScope-*")
+	defer func() {
+		_ = os.RemoveAll(d)
+	}()
+	configPath := filepath.Join(d, "config.json")
+	require.NoError(t, ioutil.WriteFile(configPath, []byte(`{
+ "Scope": {
+  "Include": ["/usr", "/opt"],
+  "Exclude": ["/opt/cache"]
+ }
+}`), 0644))
+
+	testCases := []struct {
+		name        string
+		configFile  string
+		include     string
+		exclude     string
+		ignore      string
+		maxFileSize int64
+		want        config.Scope
+		wantErr     bool
+	}{
+		{
+			name: "defaults",
+			want: config.Default().Scope,
+		},
+		{
+			name:        "flags only",
+			include:     "/usr/local, /app",
+			exclude:     "*/node_modules",
+			ignore:      "/app/vendor/*",
+			maxFileSize: 1 << 20,
+			want: config.Scope{
+				Include:     []string{"/usr/local", "/app"},
+				Exclude:     []string{"/proc", "/sys", "/dev", "*/node_modules"},
+				Ignore:      []string{"*aquasec*", "/app/vendor/*"},
+				MaxFileSize: 1 << 20,
+			}.Compile(),
+		},
+		{
+			name:       "config file and flags",
+			configFile: configPath,
+			exclude:    "/usr/share",
+			want: config.Scope{
+				Include: []string{"/usr", "/opt"},
+				Exclude: []string{"/opt/cache", "/usr/share"},
+				Ignore:  []string{"*aquasec*"},
+			}.Compile(),
+		},
+		{
+			name:       "missing config file",
+			configFile: filepath.Join(d, "missing.json"),
+			wantErr:    true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			configFile, includeDirs, excludeDirs, ignoreGlobs, maxFileSize = &tc.configFile, &tc.include, &tc.exclude, &tc.ignore, &tc.maxFileSize
+			got, err := loadScope()
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func Test_discoveryArgs(t *testing.T) {
+	scanScope = config.Scope{Include: []string{"/usr"}}
+	defer func() {
+		scanScope = config.Default().Scope
+		enabledArtifacts = nil
+	}()
+
+	assert.Equal(t, []string{"elf", "/usr", "-type", "f", "-executable"}, discoveryArgs())
+
+	enabledArtifacts = map[artifact.Category]bool{artifact.JARs: true}
+	args := discoveryArgs()
+	assert.Equal(t, []string{"all", "/usr", "-type", "f", "("}, args[:5])
+	assert.Contains(t, args, "*.jar")
+}
+
+func Test_binaryComponents(t *testing.T) {
+	varString := func(s string) []byte {
+		b := make([]byte, binary.MaxVarintLen64)
+		return append(b[:binary.PutUvarint(b, uint64(len(s)))], s...)
+	}
+	buildInfo := make([]byte, 32)
+	copy(buildInfo, "\xff Go buildinf:")
+	buildInfo[14], buildInfo[15] = 8, 2
+	buildInfo = append(buildInfo, varString("go1.13.6")...)
+	buildInfo = append(buildInfo, varString("path\tgithub.com/tianon/gosu\n"+
+		"mod\tgithub.com/tianon/gosu\t(devel)\t\n"+
+		"dep\tgithub.com/opencontainers/runc\tv0.1.1\th1:abc=\n")...)
+
+	deps := &bytes.Buffer{}
+	w := zlib.NewWriter(deps)
+	_, _ = w.Write([]byte(`{"packages":[{"name":"app","version":"0.1.0","source":"local"},` +
+		`{"name":"regex","version":"1.5.4","source":"crates.io"},{"name":"cc","version":"1.0.72","source":"crates.io","kind":"build"}]}`))
+	require.NoError(t, w.Close())
+
+	f := elftest.File{Sections: []elftest.Section{
+		{Name: ".go.buildinfo", Type: elf.SHT_PROGBITS, Flags: elf.SHF_ALLOC | elf.SHF_WRITE, Data: buildInfo},
+		{Name: ".dep-v0", Type: elf.SHT_PROGBITS, Data: deps.Bytes()},
+	}}
+	info, err := elfinfo.Read(bytes.NewReader(f.Bytes()))
+	require.NoError(t, err)
+	defer info.Close()
+
+	release := &catalog.Match{Entry: catalog.Entry{Project: "gosu", Version: "1.12", Ecosystem: "Go", Package: "github.com/tianon/gosu"}}
+	assert.Equal(t, []vuln.Component{
+		{Ecosystem: "Go", Name: "stdlib", Version: "1.13.6"},
+		{Ecosystem: "Go", Name: "github.com/opencontainers/runc", Version: "0.1.1"},
+		{Ecosystem: "crates.io", Name: "regex", Version: "1.5.4"},
+		{Ecosystem: "Go", Name: "github.com/tianon/gosu", Version: "1.12"},
+	}, binaryComponents(info, release))
+}
+
+func Test_probeCommand(t *testing.T) {
+	assert.Equal(t, []string{"run", "--rm", "--name", "binfinder-probe-1", "--network", "none", "--read-only",
+		"--cap-drop", "ALL", "--security-opt", "no-new-privileges", "-u", "65534:65534", "--memory", "64m",
+		"--memory-swap", "64m", "--cpus", "0.5", "--pids-limit", "32", "--entrypoint", "/usr/local/bin/yq",
+		"alpine:3.12", "version", "--short"},
+		probeCommand("binfinder-probe-1", "alpine:3.12", "/usr/local/bin/yq", "version --short"))
+}
+
+func Test_firstLine(t *testing.T) {
+	assert.Equal(t, "gosu 1.12", firstLine([]byte("\n  gosu 1.12  \nlicense: Apache-2.0\n")))
+	assert.Equal(t, "", firstLine([]byte("\n\n")))
+	assert.Len(t, firstLine([]byte(strings.Repeat("x", 500))), maxProbeOutput)
+}
+
+func Test_compareWithBase(t *testing.T) {
+	goldenDir := "goldens/base-data"
+	outputDir = &goldenDir
+	app := Diffs{
+		ImageName: "app",
+		ELFNames:  []string{"/app/server", "/usr/local/bin/pip-helper", "/usr/local/bin/python3.9"},
+		Binaries: []Binary{
+			{Path: "/app/server", SHA256: "cccc"},
+			{Path: "/usr/local/bin/pip-helper", SHA256: "aaaa"},
+			{Path: "/usr/local/bin/python3.9", SHA256: "dddd"},
+		},
+		Layers:    []string{"sha256:1111", "sha256:2222", "sha256:3333"},
+		BuiltFrom: &layerindex.Match{Image: "python:3.9", Layers: 2},
+	}
+	aliased := app
+	aliased.BuiltFrom = &layerindex.Match{Image: "python:3", Aliases: []string{"python:3.9"}, Layers: 2}
+	unscanned := app
+	unscanned.BuiltFrom = &layerindex.Match{Image: "python:3", Layers: 2}
+
+	detected := &BaseComparison{Image: "python:3.9", Detected: true, Added: []string{"/app/server"},
+		Changed:   []BinaryChange{{Path: "/usr/local/bin/python3.9", BaseSHA256: "bbbb", SHA256: "dddd"}},
+		Inherited: []string{"/usr/local/bin/pip-helper"}}
+	testCases := []struct {
+		name string
+		base string
+		diff Diffs
+		want *BaseComparison
+	}{
+		{
+			name: "built from a scanned image",
+			diff: app,
+			want: detected,
+		},
+		{
+			name: "alias of the image built from",
+			diff: aliased,
+			want: detected,
+		},
+		{
+			name: "built from an image not scanned",
+			diff: unscanned,
+		},
+		{
+			name: "given base",
+			base: "redis",
+			diff: app,
+			want: &BaseComparison{Image: "redis", Added: app.ELFNames, Removed: []string{"/usr/local/bin/redis-server"}},
+		},
+		{
+			name: "base image itself",
+			base: "redis",
+			diff: Diffs{ImageName: "redis", Layers: []string{"sha256:9999", "sha256:8888"}},
+		},
+		{
+			name: "no base identified",
+			diff: Diffs{ImageName: "nginx", Layers: []string{"sha256:7777", "sha256:6666"}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			baseImage = &tc.base
+			assert.Equal(t, tc.want, compareWithBase(tc.diff))
+		})
+	}
+	none := ""
+	baseImage = &none
+}
+
+func Test_scanWaves(t *testing.T) {
+	layers := map[string][]string{
+		"app":        {"sha256:1111", "sha256:2222", "sha256:3333"},
+		"python:3.9": {"sha256:1111", "sha256:2222"},
+		"debian":     {"sha256:1111"},
+		"worker":     {"sha256:1111", "sha256:2222", "sha256:4444"},
+		"nginx":      {"sha256:7777", "sha256:6666"},
+	}
+	assert.Equal(t, [][]string{{"nginx", "debian", "missing"}, {"python:3.9"}, {"app", "worker"}},
+		scanWaves([]string{"app", "nginx", "python:3.9", "debian", "worker", "missing"}, layers))
+	assert.Equal(t, [][]string{{"app", "nginx"}}, scanWaves([]string{"app", "nginx"}, layers))
+	assert.Equal(t, [][]string{nil}, scanWaves(nil, layers))
+}
+
+func Test_dropInherited(t *testing.T) {
+	d := Diffs{
+		ImageName: "app",
+		ELFNames:  []string{"/app/server", "/usr/local/bin/pip-helper", "/usr/local/bin/python3.9"},
+		Binaries: []Binary{
+			{Path: "/app/server", Packing: &elfinfo.Packing{Packer: "UPX"}},
+			{Path: "/usr/local/bin/pip-helper", Packing: &elfinfo.Packing{Packer: "UPX"}},
+		},
+		Privileged: []privilege.Privilege{{Path: "/usr/local/bin/pip-helper"}},
+		Base:       &BaseComparison{Image: "python:3.9", Inherited: []string{"/usr/local/bin/pip-helper"}},
+	}
+	full := make(map[string]int64)
+	summarizeDiff(d, full)
+
+	dropInherited(&d)
+	assert.Equal(t, []string{"/app/server", "/usr/local/bin/python3.9"}, d.ELFNames)
+	assert.Equal(t, map[string]bool{"/usr/local/bin/pip-helper": true}, inheritedOnly(d))
+	summary := make(map[string]int64)
+	summarizeDiff(d, summary)
+	assert.Equal(t, int64(3), full["unmanaged_binaries"])
+	assert.Equal(t, int64(2), full["packed_binaries"])
+	assert.Equal(t, int64(1), full["privileged_binaries"])
+	assert.Equal(t, int64(2), summary["unmanaged_binaries"])
+	assert.Equal(t, int64(1), summary["packed_binaries"])
+	assert.Equal(t, int64(0), summary["privileged_binaries"])
+}
+
+func Test_suggestPackages(t *testing.T) {
+	dir := "pkg/distindex/testdata"
+	distroIndexDir = &dir
+	defer func() {
+		none := ""
+		distroIndexDir = &none
+	}()
+	d := Diffs{
+		ImageName: "app",
+		ELFNames:  []string{"/usr/local/bin/gosu"},
+		Binaries:  []Binary{{Path: "/opt/busybox"}, {Path: "/usr/local/bin/gosu"}},
+		Declared:  []string{"/opt/busybox"},
+		release:   "debian-11",
+	}
+	suggestPackages(&d)
+	require.NotNil(t, d.Remediation)
+	assert.Equal(t, []string{"gosu"}, d.Remediation.Packages)
+	assert.Equal(t, "debian-11", d.Remediation.Release)
+	assert.Nil(t, d.Binaries[0].DistroPackages)
+	assert.NotEmpty(t, d.Binaries[1].DistroPackages)
+}
+
+func Test_writeDiffFile(t *testing.T) {
+	d, err := ioutil.TempDir("", "binfinder-diff")
+	require.NoError(t, err)
+	defer os.RemoveAll(d)
+	name := filepath.Join(d, "redis-diff.json")
+	require.NoError(t, ioutil.WriteFile(name, []byte("old"), 0644))
+	require.NoError(t, writeDiffFile(name, []byte(`{"ImageName": "redis"}`)))
+	b, err := ioutil.ReadFile(name)
+	require.NoError(t, err)
+	assert.Equal(t, `{"ImageName": "redis"}`, string(b))
+	names, err := filepath.Glob(filepath.Join(d, "*"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{name}, names)
+}
+
+func Test_checkDockerfile(t *testing.T) {
+	d, err := ioutil.TempDir("", "binfinder-dockerfile")
+	require.NoError(t, err)
+	defer os.RemoveAll(d)
+	name := filepath.Join(d, "Dockerfile")
+	require.NoError(t, ioutil.WriteFile(name, []byte(`FROM golang:1.17 AS builder
+RUN go build -o /out/app .
+
+FROM debian:bullseye-slim
+RUN curl -fsSL https://example.com/tool.tgz | tar -xz -C /opt/tool
+COPY --from=builder /out/app /usr/local/bin/
+`), 0644))
+
+	predictions, err = loadPredictions(name)
+	require.NoError(t, err)
+	dockerfileName = &name
+	defer func() {
+		none := ""
+		dockerfileName = &none
+		predictions = nil
+	}()
+
+	c := checkDockerfile(Diffs{ImageName: "app", ELFNames: []string{"/opt/tool/bin/tool", "/usr/bin/extra", "/usr/local/bin/app"}})
+	require.NotNil(t, c)
+	assert.Equal(t, name, c.File)
+	require.Len(t, c.Predictions, 2)
+	assert.Equal(t, []string{"/opt/tool/bin/tool"}, c.Predictions[0].Found)
+	assert.Equal(t, []string{"/usr/local/bin/app"}, c.Predictions[1].Found)
+	assert.Equal(t, []string{"/usr/bin/extra"}, c.Unexplained)
+}
+
+func Test_packageDBMissing(t *testing.T) {
+	run := func(script string) error {
+		_, err := exec.Command("sh", "-c", script).Output()
+		return err
+	}
+	assert.True(t, packageDBMissing(run(`echo "cat: /lib/apk/db/installed: No such file or directory" >&2; exit 1`)))
+	assert.True(t, packageDBMissing(run(`echo "ls: cannot access '/var/lib/dpkg/info/': No such file or directory" >&2; exit 2`)))
+	assert.False(t, packageDBMissing(run(`echo "docker: Error response from daemon: No such file or directory" >&2; exit 125`)),
+		"docker failure")
+	assert.False(t, packageDBMissing(run(`echo "sh: rpm: not found" >&2; exit 127`)), "missing command")
+	assert.False(t, packageDBMissing(run(`echo "error: cannot open Packages database" >&2; exit 1`)), "other error")
+	assert.False(t, packageDBMissing(exec.ErrNotFound), "docker not installed")
+	assert.False(t, packageDBMissing(nil))
+}
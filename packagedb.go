@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/aquasecurity/binfinder/pkg/pkgdb"
+)
+
+// packageDBRemoved marks the images whose package database is missing or empty,
+// every file of them would otherwise be reported as unmanaged
+const packageDBRemoved = "package database removed"
+
+// packageDBMissing reports whether listing the packages failed because the
+// package database is not in the image. Other failures, of docker or of the
+// listing command, leave the image to be scanned again.
+func packageDBMissing(err error) bool {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return false
+	}
+	switch exitErr.ExitCode() {
+	case 125, 126, 127:
+		// docker run failed, or the command is not found or not executable
+		return false
+	}
+	return bytes.Contains(exitErr.Stderr, []byte("No such file or directory"))
+}
+
+// osRelease returns the <ID>-<VERSION_ID> release of the image
+func osRelease(imageName string) (string, error) {
+	out, err := exec.Command("docker",
+		strings.Split(fmt.Sprintf(checkOSName, imageName), " ")...).Output()
+	if err != nil {
+		return "", err
+	}
+	return pkgdb.Release(out), nil
+}
+
+// referencePackageFiles marks the image as having no package database and
+// returns the packaged files of the -reference-db database of its release,
+// or nil when there is none
+func referencePackageFiles(osName string, imageName string, diffJson *Diffs) map[string]string {
+	log.Printf("%v: %s OS, %v\n", imageName, osName, packageDBRemoved)
+	diffJson.PackageDatabase = packageDBRemoved
+	if *refDBDir == "" {
+		return nil
+	}
+	release, err := osRelease(imageName)
+	if err != nil {
+		log.Printf("%v: %s OS, error reading release: %v\n", imageName, osName, err)
+		return nil
+	}
+	dir, ok := pkgdb.Find(*refDBDir, release)
+	if !ok {
+		log.Printf("%v: %s OS, no reference package database for %v\n", imageName, osName, release)
+		return nil
+	}
+	files, err := pkgdb.Load(dir)
+	if err != nil {
+		log.Printf("%v: %s OS, error loading reference package database: %v\n", imageName, osName, err)
+		return nil
+	}
+	diffJson.ReferenceDatabase = filepath.Base(dir)
+	log.Printf("%v: %s OS, using reference package database %v\n", imageName, osName, diffJson.ReferenceDatabase)
+	return files
+}
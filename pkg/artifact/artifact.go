@@ -0,0 +1,215 @@
+package artifact
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Category is a kind of non-ELF executable artifact
+type Category string
+
+const (
+	Scripts Category = "scripts"
+	JARs    Category = "jars"
+	ZipApps Category = "zipapps"
+)
+
+// Categories lists every supported artifact category
+var Categories = []Category{Scripts, JARs, ZipApps}
+
+var archiveExtensions = []string{".jar", ".war", ".ear"}
+
+// Script is an executable text file started through a shebang interpreter
+type Script struct {
+	Path        string
+	Interpreter string
+}
+
+// MavenCoordinate identifies a Maven artifact bundled in a Java archive
+type MavenCoordinate struct {
+	GroupID    string
+	ArtifactID string
+	Version    string
+}
+
+// JAR is a Java archive
+type JAR struct {
+	Path  string
+	Maven []MavenCoordinate `json:",omitempty"`
+}
+
+// ZipApp is an executable zip archive run by an interpreter, such as a Python zipapp
+type ZipApp struct {
+	Path        string
+	Interpreter string `json:",omitempty"`
+}
+
+// Result holds the classified artifacts
+type Result struct {
+	Scripts []Script
+	JARs    []JAR
+	ZipApps []ZipApp
+}
+
+// ParseCategories parses a comma separated list of category names
+func ParseCategories(s string) (map[Category]bool, error) {
+	enabled := make(map[Category]bool)
+	for _, c := range strings.Split(s, ",") {
+		c = strings.ToLower(strings.TrimSpace(c))
+		if c == "" {
+			continue
+		}
+		if c == "all" {
+			for _, category := range Categories {
+				enabled[category] = true
+			}
+			continue
+		}
+		known := false
+		for _, category := range Categories {
+			if Category(c) == category {
+				enabled[category] = true
+				known = true
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown artifact category: %v", c)
+		}
+	}
+	return enabled, nil
+}
+
+// IsArchiveName reports whether name has the extension of a Java archive
+func IsArchiveName(name string) bool {
+	ext := strings.ToLower(path.Ext(name))
+	for _, e := range archiveExtensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// Shebang returns the interpreter named on the first line of a script,
+// resolving `/usr/bin/env <interpreter>` to the interpreter itself
+func Shebang(content []byte) (string, bool) {
+	if !bytes.HasPrefix(content, []byte("#!")) {
+		return "", false
+	}
+	line, err := bufio.NewReader(bytes.NewReader(content[2:])).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", false
+	}
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return "", false
+	}
+	if path.Base(fields[0]) == "env" {
+		for _, f := range fields[1:] {
+			if !strings.HasPrefix(f, "-") && !strings.Contains(f, "=") {
+				return f, true
+			}
+		}
+	}
+	return fields[0], true
+}
+
+// Classify inspects the content of an unmanaged file and adds it to the result
+// if it belongs to one of the enabled categories
+func (r *Result) Classify(name string, content io.ReaderAt, size int64, enabled map[Category]bool) {
+	head := make([]byte, 512)
+	n, _ := content.ReadAt(head, 0)
+	head = head[:n]
+	interpreter, isScript := Shebang(head)
+
+	if zr, err := zip.NewReader(content, size); err == nil {
+		if isMainArchive(zr) {
+			if enabled[ZipApps] {
+				r.ZipApps = append(r.ZipApps, ZipApp{Path: name, Interpreter: interpreter})
+			}
+			return
+		}
+		if IsArchiveName(name) || hasManifest(zr) {
+			if enabled[JARs] {
+				r.JARs = append(r.JARs, JAR{Path: name, Maven: MavenCoordinates(zr)})
+			}
+			return
+		}
+	}
+	if isScript && enabled[Scripts] {
+		r.Scripts = append(r.Scripts, Script{Path: name, Interpreter: interpreter})
+	}
+}
+
+func isMainArchive(zr *zip.Reader) bool {
+	for _, f := range zr.File {
+		if f.Name == "__main__.py" || f.Name == "__main__.pyc" {
+			return true
+		}
+	}
+	return false
+}
+
+func hasManifest(zr *zip.Reader) bool {
+	for _, f := range zr.File {
+		if strings.HasPrefix(f.Name, "META-INF/") {
+			return true
+		}
+	}
+	return false
+}
+
+// MavenCoordinates reads the Maven coordinates from every
+// META-INF/maven/<group>/<artifact>/pom.properties file in the archive
+func MavenCoordinates(zr *zip.Reader) []MavenCoordinate {
+	var coordinates []MavenCoordinate
+	for _, f := range zr.File {
+		if !strings.HasPrefix(f.Name, "META-INF/maven/") || path.Base(f.Name) != "pom.properties" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			continue
+		}
+		props := parseProperties(rc)
+		rc.Close()
+		c := MavenCoordinate{
+			GroupID:    props["groupId"],
+			ArtifactID: props["artifactId"],
+			Version:    props["version"],
+		}
+		if c.GroupID != "" || c.ArtifactID != "" {
+			coordinates = append(coordinates, c)
+		}
+	}
+	sort.Slice(coordinates, func(i, j int) bool {
+		if coordinates[i].GroupID != coordinates[j].GroupID {
+			return coordinates[i].GroupID < coordinates[j].GroupID
+		}
+		return coordinates[i].ArtifactID < coordinates[j].ArtifactID
+	})
+	return coordinates
+}
+
+func parseProperties(r io.Reader) map[string]string {
+	props := make(map[string]string)
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || line[0] == '#' || line[0] == '!' {
+			continue
+		}
+		i := strings.IndexAny(line, "=:")
+		if i < 0 {
+			continue
+		}
+		props[strings.TrimSpace(line[:i])] = strings.TrimSpace(line[i+1:])
+	}
+	return props
+}
@@ -0,0 +1,137 @@
+package artifact
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func zipFile(t *testing.T, prefix string, files map[string]string) []byte {
+	buf := bytes.NewBufferString(prefix)
+	zw := zip.NewWriter(buf)
+	zw.SetOffset(int64(len(prefix)))
+	for name, content := range files {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func TestShebang(t *testing.T) {
+	testCases := []struct {
+		name            string
+		content         string
+		wantInterpreter string
+		wantOK          bool
+	}{
+		{name: "shell", content: "#!/bin/sh\nexec kubectl \"$@\"\n", wantInterpreter: "/bin/sh", wantOK: true},
+		{name: "env", content: "#!/usr/bin/env python3\nimport sys\n", wantInterpreter: "python3", wantOK: true},
+		{name: "env with flags", content: "#!/usr/bin/env -S node --max-old-space-size=4096", wantInterpreter: "node", wantOK: true},
+		{name: "space after marker", content: "#! /bin/bash -e\n", wantInterpreter: "/bin/bash", wantOK: true},
+		{name: "no shebang", content: "echo hello\n"},
+		{name: "empty shebang", content: "#!\n"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := Shebang([]byte(tc.content))
+			assert.Equal(t, tc.wantOK, ok)
+			assert.Equal(t, tc.wantInterpreter, got)
+		})
+	}
+}
+
+func TestResult_Classify(t *testing.T) {
+	jar := zipFile(t, "", map[string]string{
+		"META-INF/MANIFEST.MF":                                "Manifest-Version: 1.0\n",
+		"META-INF/maven/org.yaml/snakeyaml/pom.properties":    "#Generated by Maven\ngroupId=org.yaml\nartifactId=snakeyaml\nversion=1.26\n",
+		"META-INF/maven/com.example/app/pom.properties":       "groupId=com.example\nartifactId=app\nversion=0.1.0\n",
+		"META-INF/maven/com.example/app/pom.xml":              "<project/>",
+		"BOOT-INF/lib/placeholder.txt":                        "",
+		"META-INF/maven/broken/pom.properties/not-a-property": "",
+	})
+	zipapp := zipFile(t, "#!/usr/bin/env python3\n", map[string]string{
+		"__main__.py": "print('hello')\n",
+	})
+	plainZip := zipFile(t, "", map[string]string{"README": "docs"})
+
+	testCases := []struct {
+		name    string
+		path    string
+		content []byte
+		enabled map[Category]bool
+		want    Result
+	}{
+		{
+			name:    "script",
+			path:    "/usr/local/bin/kubectl",
+			content: []byte("#!/bin/sh\nexec /opt/kubectl/kubectl \"$@\"\n"),
+			enabled: map[Category]bool{Scripts: true},
+			want:    Result{Scripts: []Script{{Path: "/usr/local/bin/kubectl", Interpreter: "/bin/sh"}}},
+		},
+		{
+			name:    "jar with maven coordinates",
+			path:    "/app/app.jar",
+			content: jar,
+			enabled: map[Category]bool{JARs: true},
+			want: Result{JARs: []JAR{{Path: "/app/app.jar", Maven: []MavenCoordinate{
+				{GroupID: "com.example", ArtifactID: "app", Version: "0.1.0"},
+				{GroupID: "org.yaml", ArtifactID: "snakeyaml", Version: "1.26"},
+			}}}},
+		},
+		{
+			name:    "zipapp with shebang is not reported as a script",
+			path:    "/usr/local/bin/tool.pyz",
+			content: zipapp,
+			enabled: map[Category]bool{Scripts: true, ZipApps: true},
+			want:    Result{ZipApps: []ZipApp{{Path: "/usr/local/bin/tool.pyz", Interpreter: "python3"}}},
+		},
+		{
+			name:    "disabled category",
+			path:    "/app/app.jar",
+			content: jar,
+			enabled: map[Category]bool{Scripts: true},
+		},
+		{
+			name:    "zip without manifest",
+			path:    "/data/archive",
+			content: plainZip,
+			enabled: map[Category]bool{Scripts: true, JARs: true, ZipApps: true},
+		},
+		{
+			name:    "binary data",
+			path:    "/usr/local/bin/blob",
+			content: []byte{0x00, 0x01, 0x02},
+			enabled: map[Category]bool{Scripts: true, JARs: true, ZipApps: true},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got Result
+			got.Classify(tc.path, bytes.NewReader(tc.content), int64(len(tc.content)), tc.enabled)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestParseCategories(t *testing.T) {
+	got, err := ParseCategories("scripts, JARS")
+	require.NoError(t, err)
+	assert.Equal(t, map[Category]bool{Scripts: true, JARs: true}, got)
+
+	got, err = ParseCategories("all")
+	require.NoError(t, err)
+	assert.Equal(t, map[Category]bool{Scripts: true, JARs: true, ZipApps: true}, got)
+
+	got, err = ParseCategories("")
+	require.NoError(t, err)
+	assert.Empty(t, got)
+
+	_, err = ParseCategories("scripts,wheels")
+	assert.EqualError(t, err, "unknown artifact category: wheels")
+}
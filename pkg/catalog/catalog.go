@@ -0,0 +1,249 @@
+package catalog
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/aquasecurity/binfinder/pkg/elfinfo"
+)
+
+// Matching methods of a binary against the catalog
+const (
+	BySHA256  = "sha256"
+	ByBuildID = "build-id"
+)
+
+// URLFile is the file of a project directory holding the download URL
+// template of its releases, {version} and {file} are replaced
+const URLFile = "url"
+
+// PackageFile is the file of a project directory holding the OSV ecosystem
+// and package name of the project, e.g. "Go github.com/tianon/gosu"
+const PackageFile = "package"
+
+// Entry is a binary of a known upstream release
+type Entry struct {
+	SHA256  string
+	BuildID string `json:",omitempty"`
+	Project string
+	Version string
+	// File is the name of the release artifact, or of the file within the release archive
+	File string
+	URL  string `json:",omitempty"`
+	// Ecosystem and Package name the project in vulnerability databases
+	Ecosystem string `json:",omitempty"`
+	Package   string `json:",omitempty"`
+}
+
+// Label describes the release, e.g. "gosu 1.12 (official release)"
+func (e Entry) Label() string {
+	return fmt.Sprintf("%v %v (official release)", e.Project, e.Version)
+}
+
+// BuildIDLabel describes a binary sharing only the build ID of the release, e.g.
+// "gosu 1.12 (build-id match, content differs from release)"
+func (e Entry) BuildIDLabel() string {
+	return fmt.Sprintf("%v %v (build-id match, content differs from release)", e.Project, e.Version)
+}
+
+// Match is a catalog entry matching a binary
+type Match struct {
+	Entry
+	By    string
+	Label string
+}
+
+// Catalog indexes the binaries of known upstream releases
+type Catalog struct {
+	Entries []Entry
+
+	bySHA256  map[string]*Entry
+	byBuildID map[string]*Entry
+}
+
+// Load reads a JSON catalog file
+func Load(name string) (*Catalog, error) {
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	c := &Catalog{}
+	if err = json.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("%v: invalid catalog: %v", name, err)
+	}
+	c.index()
+	return c, nil
+}
+
+// Save writes the catalog as JSON
+func (c *Catalog) Save(name string) error {
+	b, err := json.MarshalIndent(c, "", " ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(name, b, 0644)
+}
+
+func (c *Catalog) index() {
+	c.bySHA256 = make(map[string]*Entry)
+	c.byBuildID = make(map[string]*Entry)
+	for i := range c.Entries {
+		e := &c.Entries[i]
+		c.bySHA256[e.SHA256] = e
+		if e.BuildID != "" {
+			c.byBuildID[e.BuildID] = e
+		}
+	}
+}
+
+// Lookup returns the release of the binary with the given hash and build ID,
+// the hash identifies the exact artifact while the build ID also matches
+// binaries stripped or otherwise modified after the release
+func (c *Catalog) Lookup(sha string, buildID string) *Match {
+	if e, ok := c.bySHA256[sha]; ok {
+		return &Match{Entry: *e, By: BySHA256, Label: e.Label()}
+	}
+	if buildID == "" {
+		return nil
+	}
+	if e, ok := c.byBuildID[buildID]; ok {
+		return &Match{Entry: *e, By: ByBuildID, Label: e.BuildIDLabel()}
+	}
+	return nil
+}
+
+// Build creates a catalog from a directory of release artifacts laid out as
+// <project>/<version>/<artifact>. ELF artifacts are indexed directly, the ELF
+// files of .tar.gz and .tgz artifacts are indexed one by one.
+func Build(dir string) (*Catalog, error) {
+	c := &Catalog{}
+	projects, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range projects {
+		if !p.IsDir() {
+			continue
+		}
+		projectDir := filepath.Join(dir, p.Name())
+		urlTemplate := ""
+		if b, err := ioutil.ReadFile(filepath.Join(projectDir, URLFile)); err == nil {
+			urlTemplate = strings.TrimSpace(string(b))
+		}
+		var ecosystem, pkg string
+		if b, err := ioutil.ReadFile(filepath.Join(projectDir, PackageFile)); err == nil {
+			if f := strings.Fields(string(b)); len(f) == 2 {
+				ecosystem, pkg = f[0], f[1]
+			}
+		}
+		versions, err := ioutil.ReadDir(projectDir)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range versions {
+			if !v.IsDir() {
+				continue
+			}
+			artifacts, err := ioutil.ReadDir(filepath.Join(projectDir, v.Name()))
+			if err != nil {
+				return nil, err
+			}
+			for _, a := range artifacts {
+				if a.IsDir() {
+					continue
+				}
+				release := Entry{Project: p.Name(), Version: v.Name(), File: a.Name(), Ecosystem: ecosystem, Package: pkg}
+				if urlTemplate != "" {
+					release.URL = strings.NewReplacer("{version}", v.Name(), "{file}", a.Name()).Replace(urlTemplate)
+				}
+				entries, err := artifactEntries(filepath.Join(projectDir, v.Name(), a.Name()), release)
+				if err != nil {
+					return nil, fmt.Errorf("%v/%v/%v: %v", p.Name(), v.Name(), a.Name(), err)
+				}
+				c.Entries = append(c.Entries, entries...)
+			}
+		}
+	}
+	sort.SliceStable(c.Entries, func(i, j int) bool { return c.Entries[i].SHA256 < c.Entries[j].SHA256 })
+	c.index()
+	return c, nil
+}
+
+func artifactEntries(name string, release Entry) ([]Entry, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tgz") {
+		return archiveEntries(f, release)
+	}
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	e, ok := fileEntry(b, release)
+	if !ok {
+		return nil, nil
+	}
+	return []Entry{e}, nil
+}
+
+func archiveEntries(r io.Reader, release Entry) ([]Entry, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	var entries []Entry
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		b, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		e := release
+		e.File = archivePath(hdr.Name)
+		if e, ok := fileEntry(b, e); ok {
+			entries = append(entries, e)
+		}
+	}
+}
+
+func archivePath(name string) string {
+	return strings.TrimPrefix(filepath.ToSlash(filepath.Clean(name)), "./")
+}
+
+// fileEntry completes the entry with the hash and build ID of an ELF file
+func fileEntry(b []byte, e Entry) (Entry, bool) {
+	info, err := elfinfo.Read(bytes.NewReader(b))
+	if err != nil {
+		return e, false
+	}
+	defer info.Close()
+	sum := sha256.Sum256(b)
+	e.SHA256 = hex.EncodeToString(sum[:])
+	e.BuildID = info.BuildID
+	return e, true
+}
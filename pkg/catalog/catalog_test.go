@@ -0,0 +1,91 @@
+package catalog
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"debug/elf"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aquasecurity/binfinder/pkg/elfinfo/elftest"
+)
+
+func sha(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func writeFile(t *testing.T, name string, b []byte) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(name), 0755))
+	require.NoError(t, ioutil.WriteFile(name, b, 0644))
+}
+
+func targz(t *testing.T, files map[string][]byte) []byte {
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gz)
+	for name, b := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: int64(len(b)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write(b)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func TestBuild(t *testing.T) {
+	dir, err := ioutil.TempDir("", "TestBuild-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	gosu := elftest.File{Sections: []elftest.Section{elftest.Note(".note.go.buildid", "Go", 4, []byte("gosu-build"))}}.Bytes()
+	tini := elftest.File{Sections: []elftest.Section{elftest.Note(".note.gnu.build-id", "GNU", 3, []byte{0x0a, 0x0b})}}.Bytes()
+	helm := elftest.File{Type: elf.ET_EXEC, Symbols: []string{"main"}}.Bytes()
+
+	writeFile(t, filepath.Join(dir, "gosu", URLFile), []byte("https://github.com/tianon/gosu/releases/download/{version}/{file}\n"))
+	writeFile(t, filepath.Join(dir, "gosu", PackageFile), []byte("Go github.com/tianon/gosu\n"))
+	writeFile(t, filepath.Join(dir, "gosu", "1.12", "gosu-amd64"), gosu)
+	writeFile(t, filepath.Join(dir, "gosu", "1.12", "gosu-amd64.asc"), []byte("-----BEGIN PGP SIGNATURE-----"))
+	writeFile(t, filepath.Join(dir, "tini", "0.19.0", "tini-amd64"), tini)
+	writeFile(t, filepath.Join(dir, "helm", "3.4.0", "helm-v3.4.0-linux-amd64.tar.gz"), targz(t, map[string][]byte{
+		"./linux-amd64/helm":      helm,
+		"./linux-amd64/README.md": []byte("# helm"),
+	}))
+
+	c, err := Build(dir)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []Entry{
+		{SHA256: sha(gosu), BuildID: "gosu-build", Project: "gosu", Version: "1.12", File: "gosu-amd64",
+			URL: "https://github.com/tianon/gosu/releases/download/1.12/gosu-amd64", Ecosystem: "Go", Package: "github.com/tianon/gosu"},
+		{SHA256: sha(tini), BuildID: "0a0b", Project: "tini", Version: "0.19.0", File: "tini-amd64"},
+		{SHA256: sha(helm), Project: "helm", Version: "3.4.0", File: "linux-amd64/helm"},
+	}, c.Entries)
+
+	name := filepath.Join(dir, "catalog.json")
+	require.NoError(t, c.Save(name))
+	c, err = Load(name)
+	require.NoError(t, err)
+
+	m := c.Lookup(sha(gosu), "gosu-build")
+	require.NotNil(t, m)
+	assert.Equal(t, BySHA256, m.By)
+	assert.Equal(t, "gosu 1.12 (official release)", m.Label)
+
+	m = c.Lookup(sha([]byte("stripped tini")), "0a0b")
+	require.NotNil(t, m)
+	assert.Equal(t, ByBuildID, m.By)
+	assert.Equal(t, "tini", m.Project)
+	assert.Equal(t, "tini 0.19.0 (build-id match, content differs from release)", m.Label)
+
+	assert.Nil(t, c.Lookup(sha([]byte("unknown")), ""))
+	assert.Nil(t, c.Lookup(sha([]byte("unknown")), "ffff"))
+}
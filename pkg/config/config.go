@@ -0,0 +1,187 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Config is the binfinder configuration file
+type Config struct {
+	Scope Scope
+}
+
+// Scope restricts which files are considered during discovery
+type Scope struct {
+	// Include are the root directories searched for files
+	Include []string
+	// Exclude are path globs of files and directories not descended into
+	Exclude []string
+	// Ignore are path globs of discovered files left out of the results
+	Ignore []string
+	// MaxFileSize skips files larger than this many bytes, 0 means no limit
+	MaxFileSize int64
+
+	// Exclude and Ignore compiled by Compile, nil for an invalid pattern
+	exclude, ignore []*regexp.Regexp
+}
+
+// Default returns the configuration used when no configuration file is given
+func Default() Config {
+	return Config{
+		Scope: Scope{
+			Include: []string{"/"},
+			Exclude: []string{"/proc", "/sys", "/dev"},
+			// binaries of the Aqua enforcer mounted into scanned containers
+			Ignore: []string{"*aquasec*"},
+		}.Compile(),
+	}
+}
+
+// Load reads a JSON configuration file, fields missing from the file keep
+// their default value
+func Load(name string) (Config, error) {
+	c := Default()
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return c, err
+	}
+	if err = json.Unmarshal(b, &c); err != nil {
+		return c, fmt.Errorf("%v: invalid configuration: %v", name, err)
+	}
+	c.Scope = c.Scope.Compile()
+	return c, nil
+}
+
+// FindArgs returns the find(1) arguments searching the include roots for
+// files matching expression while pruning excluded paths and large files
+func (s Scope) FindArgs(expression ...string) []string {
+	args := append([]string{}, s.Include...)
+	if len(args) == 0 {
+		args = append(args, "/")
+	}
+	if len(s.Exclude) > 0 {
+		args = append(args, "(")
+		for i, e := range s.Exclude {
+			if i > 0 {
+				args = append(args, "-o")
+			}
+			args = append(args, "-path", e)
+		}
+		args = append(args, ")", "-prune", "-o")
+	}
+	args = append(args, expression...)
+	if s.MaxFileSize > 0 {
+		args = append(args, "-size", "-"+strconv.FormatInt(s.MaxFileSize+1, 10)+"c")
+	}
+	return args
+}
+
+// Compile returns the scope with its Exclude and Ignore patterns compiled once,
+// it has to be called again after changing them. Skipped and Ignored compile
+// the patterns of a scope that was not compiled on each call.
+func (s Scope) Compile() Scope {
+	s.exclude = compile(s.Exclude)
+	s.ignore = compile(s.Ignore)
+	return s
+}
+
+func compile(patterns []string) []*regexp.Regexp {
+	var res []*regexp.Regexp
+	for _, p := range patterns {
+		re, _ := regexp.Compile(globToRegexp(p))
+		res = append(res, re)
+	}
+	return res
+}
+
+func matchAny(res []*regexp.Regexp, name string) bool {
+	for _, re := range res {
+		if re != nil && re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Ignored reports whether a discovered file matches one of the ignore patterns
+func (s Scope) Ignored(name string) bool {
+	if s.ignore == nil {
+		s.ignore = compile(s.Ignore)
+	}
+	return matchAny(s.ignore, name)
+}
+
+// Skipped reports whether a file is left out of the scan: outside the include
+// roots, below an excluded path, or ignored. It applies the scope to the walks
+// of the image filesystem the way FindArgs and Ignored apply it to discovery.
+func (s Scope) Skipped(name string) bool {
+	included := len(s.Include) == 0
+	for _, root := range s.Include {
+		root = path.Clean(root)
+		if root == "/" || name == root || strings.HasPrefix(name, root+"/") {
+			included = true
+			break
+		}
+	}
+	if !included {
+		return true
+	}
+	if s.exclude == nil {
+		s.exclude = compile(s.Exclude)
+	}
+	for dir := name; dir != "/" && dir != "."; dir = path.Dir(dir) {
+		if matchAny(s.exclude, dir) {
+			return true
+		}
+	}
+	return s.Ignored(name)
+}
+
+// Match reports whether name matches the shell pattern the same way
+// find -path does: '*' and '?' also match '/'
+func Match(pattern, name string) bool {
+	re, err := regexp.Compile(globToRegexp(pattern))
+	if err != nil {
+		return false
+	}
+	return re.MatchString(name)
+}
+
+func globToRegexp(pattern string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+			}
+			b.WriteString(regexp.QuoteMeta(string(pattern[i])))
+		case '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.Replace(class, `\`, `\\`, -1) + "]")
+			i += end + 1
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
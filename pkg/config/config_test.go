@@ -0,0 +1,117 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatch(t *testing.T) {
+	testCases := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{pattern: "*aquasec*", name: "/opt/aquasec/bin/slklib", want: true},
+		{pattern: "*aquasec*", name: "/usr/bin/grep"},
+		{pattern: "/proc", name: "/proc", want: true},
+		{pattern: "/proc", name: "/proc/1/exe"},
+		{pattern: "*/node_modules", name: "/app/node_modules", want: true},
+		{pattern: "/usr/lib/go-1.1?/*", name: "/usr/lib/go-1.15/bin/go", want: true},
+		{pattern: "/usr/bin/[!p]*", name: "/usr/bin/python3"},
+		{pattern: "/usr/bin/[!p]*", name: "/usr/bin/curl", want: true},
+		{pattern: "/tmp/a.b", name: "/tmp/axb"},
+		{pattern: `/tmp/\*`, name: "/tmp/*", want: true},
+		{pattern: `/tmp/\*`, name: "/tmp/x"},
+		{pattern: "/tmp/[", name: "/tmp/[", want: true},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.want, Match(tc.pattern, tc.name), "%v ~ %v", tc.pattern, tc.name)
+	}
+}
+
+func TestScope_FindArgs(t *testing.T) {
+	testCases := []struct {
+		name  string
+		scope Scope
+		want  []string
+	}{
+		{
+			name:  "default",
+			scope: Default().Scope,
+			want: []string{"/", "(", "-path", "/proc", "-o", "-path", "/sys", "-o", "-path", "/dev", ")", "-prune", "-o",
+				"-type", "f"},
+		},
+		{
+			name:  "include roots and size limit",
+			scope: Scope{Include: []string{"/usr", "/opt"}, MaxFileSize: 1024},
+			want:  []string{"/usr", "/opt", "-type", "f", "-size", "-1025c"},
+		},
+		{
+			name:  "no include roots",
+			scope: Scope{Exclude: []string{"*/.git"}},
+			want:  []string{"/", "(", "-path", "*/.git", ")", "-prune", "-o", "-type", "f"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, tc.scope.FindArgs("-type", "f"))
+		})
+	}
+}
+
+func TestScope_Skipped(t *testing.T) {
+	scope := Scope{
+		Include: []string{"/usr", "/opt/"},
+		Exclude: []string{"/usr/share", "*/node_modules"},
+		Ignore:  []string{"*aquasec*"},
+	}
+	testCases := []struct {
+		name string
+		want bool
+	}{
+		{name: "/usr/bin/gcc"},
+		{name: "/opt/app/bin/server"},
+		{name: "/usr/share/doc/gcc/README", want: true},
+		{name: "/opt/app/node_modules/esbuild/bin/esbuild", want: true},
+		{name: "/opt/aquasec/bin/slklib", want: true},
+		{name: "/usrlocal/bin/app", want: true},
+		{name: "/etc/passwd", want: true},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.want, scope.Skipped(tc.name), tc.name)
+		assert.Equal(t, tc.want, scope.Compile().Skipped(tc.name), "compiled %v", tc.name)
+	}
+	invalid := Scope{Exclude: []string{"/usr/[z-a]"}, Ignore: []string{"[z-a]*"}}.Compile()
+	assert.False(t, invalid.Skipped("/usr/z"))
+	assert.False(t, Default().Scope.Skipped("/usr/local/go/bin/go"))
+	assert.True(t, Default().Scope.Skipped("/proc/1/exe"))
+}
+
+func TestLoad(t *testing.T) {
+	d, _ := ioutil.TempDir("", "TestLoad-*")
+	defer func() {
+		_ = os.RemoveAll(d)
+	}()
+	valid := filepath.Join(d, "valid.json")
+	require.NoError(t, ioutil.WriteFile(valid, []byte(`{"Scope": {"Include": ["/usr/local"], "MaxFileSize": 1048576}}`), 0644))
+	invalid := filepath.Join(d, "invalid.json")
+	require.NoError(t, ioutil.WriteFile(invalid, []byte(`{"Scope": [`), 0644))
+
+	c, err := Load(valid)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/usr/local"}, c.Scope.Include)
+	assert.Equal(t, int64(1048576), c.Scope.MaxFileSize)
+	assert.Equal(t, Default().Scope.Exclude, c.Scope.Exclude)
+	assert.True(t, c.Scope.Ignored("/opt/aquasec/slklib"))
+
+	_, err = Load(invalid)
+	assert.Error(t, err)
+
+	_, err = Load(filepath.Join(d, "missing.json"))
+	assert.Error(t, err)
+}
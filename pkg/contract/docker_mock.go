@@ -64,3 +64,34 @@ func (mr *MockDockerContractMockRecorder) ImagePull(ctx, ref, options interface{
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ImagePull", reflect.TypeOf((*MockDockerContract)(nil).ImagePull), ctx, ref, options)
 }
+
+// ImageInspectWithRaw mocks base method
+func (m *MockDockerContract) ImageInspectWithRaw(ctx context.Context, imageID string) (types.ImageInspect, []byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ImageInspectWithRaw", ctx, imageID)
+	ret0, _ := ret[0].(types.ImageInspect)
+	ret1, _ := ret[1].([]byte)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ImageInspectWithRaw indicates an expected call of ImageInspectWithRaw
+func (mr *MockDockerContractMockRecorder) ImageInspectWithRaw(ctx, imageID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ImageInspectWithRaw", reflect.TypeOf((*MockDockerContract)(nil).ImageInspectWithRaw), ctx, imageID)
+}
+
+// ImageHistory mocks base method
+func (m *MockDockerContract) ImageHistory(ctx context.Context, imageID string) ([]types.ImageHistory, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ImageHistory", ctx, imageID)
+	ret0, _ := ret[0].([]types.ImageHistory)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ImageHistory indicates an expected call of ImageHistory
+func (mr *MockDockerContractMockRecorder) ImageHistory(ctx, imageID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ImageHistory", reflect.TypeOf((*MockDockerContract)(nil).ImageHistory), ctx, imageID)
+}
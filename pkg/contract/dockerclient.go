@@ -11,4 +11,6 @@ import (
 type DockerContract interface {
 	Info(ctx context.Context) (types.Info, error)
 	ImagePull(ctx context.Context, ref string, options types.ImagePullOptions) (io.ReadCloser, error)
+	ImageInspectWithRaw(ctx context.Context, imageID string) (types.ImageInspect, []byte, error)
+	ImageHistory(ctx context.Context, imageID string) ([]types.ImageHistory, error)
 }
@@ -0,0 +1,94 @@
+package declare
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// LabelPrefix starts the image labels declaring the unmanaged binaries expected in
+// the image, one io.aquasec.binfinder.binary.<id>.<key> label per key:
+//
+//	path    path glob of the binaries, required
+//	url     upstream URL the binaries come from
+//	sha256  hex SHA-256 the binaries must have
+const LabelPrefix = "io.aquasec.binfinder.binary."
+
+// Declaration is an unmanaged binary the image author expects in the image
+type Declaration struct {
+	ID     string
+	Path   string
+	URL    string `json:",omitempty"`
+	SHA256 string `json:",omitempty"`
+}
+
+// Mismatch is a binary matching the path of a declaration with another content than declared
+type Mismatch struct {
+	Path        string
+	Declaration string
+	Expected    string
+	SHA256      string
+}
+
+// Parse returns the declarations of the image labels, sorted by ID, and the
+// errors of the declarations left out
+func Parse(labels map[string]string) ([]Declaration, []error) {
+	byID := make(map[string]*Declaration)
+	var errs []error
+	for k, v := range labels {
+		if !strings.HasPrefix(k, LabelPrefix) {
+			continue
+		}
+		rest := k[len(LabelPrefix):]
+		i := strings.LastIndex(rest, ".")
+		if i <= 0 {
+			errs = append(errs, fmt.Errorf("%v: expected %v<id>.<key>", k, LabelPrefix))
+			continue
+		}
+		id, key := rest[:i], rest[i+1:]
+		d, ok := byID[id]
+		if !ok {
+			d = &Declaration{ID: id}
+			byID[id] = d
+		}
+		v = strings.TrimSpace(v)
+		switch key {
+		case "path":
+			d.Path = v
+		case "url":
+			d.URL = v
+		case "sha256":
+			d.SHA256 = strings.ToLower(strings.TrimPrefix(v, "sha256:"))
+		default:
+			errs = append(errs, fmt.Errorf("%v: unknown key %q", k, key))
+		}
+	}
+	var decls []Declaration
+	for _, d := range byID {
+		if _, err := path.Match(d.Path, "/"); d.Path == "" || err != nil {
+			errs = append(errs, fmt.Errorf("%v%v: missing or invalid path %q", LabelPrefix, d.ID, d.Path))
+			continue
+		}
+		decls = append(decls, *d)
+	}
+	sort.Slice(decls, func(i, j int) bool { return decls[i].ID < decls[j].ID })
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Error() < errs[j].Error() })
+	return decls, errs
+}
+
+// Match returns the first declaration whose path glob matches the binary, and
+// a mismatch when the declared hash differs from the hash of the binary
+func Match(decls []Declaration, binary, sha256 string) (*Declaration, *Mismatch) {
+	for i := range decls {
+		d := &decls[i]
+		if ok, _ := path.Match(d.Path, binary); !ok {
+			continue
+		}
+		if d.SHA256 != "" && d.SHA256 != sha256 {
+			return nil, &Mismatch{Path: binary, Declaration: d.ID, Expected: d.SHA256, SHA256: sha256}
+		}
+		return d, nil
+	}
+	return nil, nil
+}
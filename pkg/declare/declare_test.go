@@ -0,0 +1,56 @@
+package declare
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	decls, errs := Parse(map[string]string{
+		"org.opencontainers.image.title":               "redis",
+		"io.aquasec.binfinder.binary.gosu.path":        "/usr/local/bin/gosu",
+		"io.aquasec.binfinder.binary.gosu.url":         "https://github.com/tianon/gosu/releases/download/1.12/gosu-amd64",
+		"io.aquasec.binfinder.binary.gosu.sha256":      "sha256:BBC1D54E",
+		"io.aquasec.binfinder.binary.redis.path":       "/usr/local/bin/redis-*",
+		"io.aquasec.binfinder.binary.tini.url":         "https://github.com/krallin/tini",
+		"io.aquasec.binfinder.binary.redis.maintainer": "someone",
+		"io.aquasec.binfinder.binary.nokey":            "x",
+	})
+	assert.Equal(t, []Declaration{
+		{ID: "gosu", Path: "/usr/local/bin/gosu", URL: "https://github.com/tianon/gosu/releases/download/1.12/gosu-amd64", SHA256: "bbc1d54e"},
+		{ID: "redis", Path: "/usr/local/bin/redis-*"},
+	}, decls)
+	var messages []string
+	for _, err := range errs {
+		messages = append(messages, err.Error())
+	}
+	assert.Equal(t, []string{
+		`io.aquasec.binfinder.binary.nokey: expected io.aquasec.binfinder.binary.<id>.<key>`,
+		`io.aquasec.binfinder.binary.redis.maintainer: unknown key "maintainer"`,
+		`io.aquasec.binfinder.binary.tini: missing or invalid path ""`,
+	}, messages)
+}
+
+func TestMatch(t *testing.T) {
+	decls := []Declaration{
+		{ID: "gosu", Path: "/usr/local/bin/gosu", SHA256: "bbc1"},
+		{ID: "redis", Path: "/usr/local/bin/redis-*"},
+	}
+
+	d, m := Match(decls, "/usr/local/bin/gosu", "bbc1")
+	assert.Equal(t, &decls[0], d)
+	assert.Nil(t, m)
+
+	d, m = Match(decls, "/usr/local/bin/gosu", "ffff")
+	assert.Nil(t, d)
+	assert.Equal(t, &Mismatch{Path: "/usr/local/bin/gosu", Declaration: "gosu", Expected: "bbc1", SHA256: "ffff"}, m)
+
+	d, m = Match(decls, "/usr/local/bin/redis-server", "aaaa")
+	assert.Equal(t, &decls[1], d)
+	assert.Nil(t, m)
+
+	d, m = Match(decls, "/usr/bin/redis-server", "aaaa")
+	assert.Nil(t, d)
+	assert.Nil(t, m)
+}
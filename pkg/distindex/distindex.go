@@ -0,0 +1,260 @@
+package distindex
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Package managers of the distributions, told apart by the index files found
+const (
+	APK = "apk"
+	APT = "apt"
+	YUM = "yum"
+)
+
+// Candidate is a package of the distribution shipping a file of the looked up name
+type Candidate struct {
+	Package string
+	Path    string
+}
+
+// Index maps the names of the commands and shared libraries of a distribution release to their packages
+type Index struct {
+	// Manager is the package manager installing the packages of the index
+	Manager string
+	files   map[string][]Candidate
+}
+
+// Load reads the package indexes of a release directory: Debian Contents-<arch>
+// files, Alpine APKINDEX archives or RPM repository filelists metadata, gzipped or not
+func Load(dir string) (*Index, error) {
+	ix := &Index{files: make(map[string][]Candidate)}
+	loaders := []struct {
+		manager string
+		pattern string
+		load    func(*Index, io.Reader) error
+	}{
+		{APT, "Contents-*", (*Index).loadContents},
+		{APK, "APKINDEX*", (*Index).loadAPKIndex},
+		{YUM, "*filelists.xml*", (*Index).loadFilelists},
+	}
+	for _, l := range loaders {
+		names, err := filepath.Glob(filepath.Join(dir, l.pattern))
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			if err = ix.loadFile(name, l.load); err != nil {
+				return nil, fmt.Errorf("%v: %v", name, err)
+			}
+			ix.Manager = l.manager
+		}
+		if ix.Manager != "" {
+			break
+		}
+	}
+	if ix.Manager == "" {
+		return nil, fmt.Errorf("%v: no Contents-<arch>, APKINDEX or filelists.xml package index", dir)
+	}
+	for name := range ix.files {
+		sort.Slice(ix.files[name], func(i, j int) bool {
+			a, b := ix.files[name][i], ix.files[name][j]
+			if a.Package != b.Package {
+				return a.Package < b.Package
+			}
+			return a.Path < b.Path
+		})
+	}
+	return ix, nil
+}
+
+func (ix *Index) loadFile(name string, load func(*Index, io.Reader) error) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(name, ".gz") && !strings.HasSuffix(name, ".tar.gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	return load(ix, r)
+}
+
+// indexed reports whether a packaged file is a command or a shared library,
+// the only files looked up
+func indexed(name string) bool {
+	dir := path.Base(path.Dir(name))
+	return dir == "bin" || dir == "sbin" || strings.Contains(path.Base(name), ".so")
+}
+
+func (ix *Index) add(pkg, name string) {
+	if !strings.HasPrefix(name, "/") {
+		name = "/" + name
+	}
+	if !indexed(name) {
+		return
+	}
+	base := path.Base(name)
+	for _, c := range ix.files[base] {
+		if c.Package == pkg && c.Path == name {
+			return
+		}
+	}
+	ix.files[base] = append(ix.files[base], Candidate{Package: pkg, Path: name})
+}
+
+// loadContents reads a Debian Contents file, "<path> <section>/<package>[,...]" lines
+func (ix *Index) loadContents(r io.Reader) error {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		i := strings.LastIndexAny(line, " \t")
+		if i < 0 {
+			continue
+		}
+		name, pkgs := strings.TrimSpace(line[:i]), line[i+1:]
+		if name == "FILE" {
+			continue
+		}
+		for _, p := range strings.Split(pkgs, ",") {
+			ix.add(path.Base(p), name)
+		}
+	}
+	return s.Err()
+}
+
+// loadAPKIndex reads an APKINDEX, as is or in its APKINDEX.tar.gz archive.
+// APKINDEX lists no files, the "cmd:" and "so:" names a package provides stand for them.
+func (ix *Index) loadAPKIndex(r io.Reader) error {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		tr := tar.NewReader(gz)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				return fmt.Errorf("no APKINDEX in archive")
+			}
+			if err != nil {
+				return err
+			}
+			if hdr.Name == "APKINDEX" {
+				b, err := ioutil.ReadAll(tr)
+				if err != nil {
+					return err
+				}
+				ix.parseAPKIndex(b)
+				return nil
+			}
+		}
+	}
+	b, err := ioutil.ReadAll(br)
+	if err != nil {
+		return err
+	}
+	ix.parseAPKIndex(b)
+	return nil
+}
+
+func (ix *Index) parseAPKIndex(data []byte) {
+	var pkg string
+	s := bufio.NewScanner(bytes.NewReader(data))
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+	for s.Scan() {
+		line := s.Text()
+		if len(line) < 2 || line[1] != ':' {
+			continue
+		}
+		switch line[0] {
+		case 'P':
+			pkg = line[2:]
+		case 'p':
+			for _, p := range strings.Fields(line[2:]) {
+				name := strings.SplitN(p, "=", 2)[0]
+				switch {
+				case strings.HasPrefix(name, "cmd:"):
+					ix.add(pkg, "/usr/bin/"+strings.TrimPrefix(name, "cmd:"))
+				case strings.HasPrefix(name, "so:"):
+					ix.add(pkg, "/usr/lib/"+strings.TrimPrefix(name, "so:"))
+				}
+			}
+		}
+	}
+}
+
+// loadFilelists reads the filelists metadata of an RPM repository
+func (ix *Index) loadFilelists(r io.Reader) error {
+	d := xml.NewDecoder(r)
+	var pkg string
+	for {
+		t, err := d.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		start, ok := t.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "package":
+			for _, a := range start.Attr {
+				if a.Name.Local == "name" {
+					pkg = a.Value
+				}
+			}
+		case "file":
+			var name string
+			if err = d.DecodeElement(&name, &start); err != nil {
+				return err
+			}
+			ix.add(pkg, strings.TrimSpace(name))
+		}
+	}
+}
+
+// Lookup returns the packages shipping a command or shared library of the same name as the binary
+func (ix *Index) Lookup(binary string) []Candidate {
+	return ix.files[path.Base(binary)]
+}
+
+// Snippet returns the Dockerfile instruction installing the packages
+func Snippet(manager string, packages []string) string {
+	if len(packages) == 0 {
+		return ""
+	}
+	list := strings.Join(packages, " ")
+	switch manager {
+	case APK:
+		return "RUN apk add --no-cache " + list
+	case APT:
+		return "RUN apt-get update && apt-get install -y --no-install-recommends " + list + " && rm -rf /var/lib/apt/lists/*"
+	case YUM:
+		return "RUN yum install -y " + list + " && yum clean all"
+	}
+	return ""
+}
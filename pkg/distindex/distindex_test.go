@@ -0,0 +1,111 @@
+package distindex
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const apkIndex = `C:Q1abc=
+P:jq
+V:1.6-r1
+p:cmd:jq=1.6-r1
+
+P:oniguruma
+V:6.9.7.1-r0
+p:so:libonig.so.5=5.1.0
+
+P:su-exec
+V:0.2-r1
+p:cmd:su-exec=0.2-r1
+`
+
+func writeAPKIndex(t *testing.T, dir string) {
+	f, err := os.Create(filepath.Join(dir, "APKINDEX.tar.gz"))
+	require.NoError(t, err)
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "DESCRIPTION", Mode: 0644, Size: 4}))
+	_, err = tw.Write([]byte("main"))
+	require.NoError(t, err)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "APKINDEX", Mode: 0644, Size: int64(len(apkIndex))}))
+	_, err = tw.Write([]byte(apkIndex))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+}
+
+func TestLoad(t *testing.T) {
+	alpine, err := ioutil.TempDir("", "distindex")
+	require.NoError(t, err)
+	defer os.RemoveAll(alpine)
+	writeAPKIndex(t, alpine)
+
+	testCases := []struct {
+		name    string
+		dir     string
+		manager string
+		lookups map[string][]Candidate
+	}{
+		{
+			name:    "debian Contents",
+			dir:     "testdata/debian-11",
+			manager: APT,
+			lookups: map[string][]Candidate{
+				"/usr/local/bin/gosu": {{Package: "gosu", Path: "/usr/bin/gosu"}},
+				"/opt/busybox": {{Package: "busybox", Path: "/bin/busybox"},
+					{Package: "busybox-static", Path: "/bin/busybox"}},
+				"/app/libjq.so.1": {{Package: "libjq1", Path: "/usr/lib/x86_64-linux-gnu/libjq.so.1"}},
+				"/app/README":     nil,
+			},
+		},
+		{
+			name:    "alpine APKINDEX",
+			dir:     alpine,
+			manager: APK,
+			lookups: map[string][]Candidate{
+				"/usr/local/bin/jq":     {{Package: "jq", Path: "/usr/bin/jq"}},
+				"/app/lib/libonig.so.5": {{Package: "oniguruma", Path: "/usr/lib/libonig.so.5"}},
+				"/usr/local/bin/gosu":   nil,
+			},
+		},
+		{
+			name:    "RPM filelists",
+			dir:     "testdata/centos-8",
+			manager: YUM,
+			lookups: map[string][]Candidate{
+				"/usr/local/bin/jq":   {{Package: "jq", Path: "/usr/bin/jq"}},
+				"/bin/busybox":        {{Package: "busybox", Path: "/sbin/busybox"}},
+				"/usr/local/bin/tini": nil,
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ix, err := Load(tc.dir)
+			require.NoError(t, err)
+			assert.Equal(t, tc.manager, ix.Manager)
+			for binary, want := range tc.lookups {
+				assert.Equal(t, want, ix.Lookup(binary), binary)
+			}
+		})
+	}
+
+	_, err = Load("testdata")
+	assert.Error(t, err)
+}
+
+func TestSnippet(t *testing.T) {
+	assert.Equal(t, "RUN apk add --no-cache jq su-exec", Snippet(APK, []string{"jq", "su-exec"}))
+	assert.Equal(t, "RUN apt-get update && apt-get install -y --no-install-recommends gosu && rm -rf /var/lib/apt/lists/*",
+		Snippet(APT, []string{"gosu"}))
+	assert.Equal(t, "RUN yum install -y jq && yum clean all", Snippet(YUM, []string{"jq"}))
+	assert.Equal(t, "", Snippet(APT, nil))
+}
@@ -0,0 +1,429 @@
+package dockerfile
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Kinds of instructions introducing binaries outside the package manager
+const (
+	AddURL             = "add-url"
+	CopyFromStage      = "copy-from-stage"
+	DownloadArchive    = "download-archive"
+	DownloadExecutable = "download-executable"
+)
+
+// Instruction is a Dockerfile instruction, continuation lines joined
+type Instruction struct {
+	// Line is the line number of the instruction in the Dockerfile
+	Line int
+	// Command is the upper-cased instruction keyword, e.g. RUN
+	Command string
+	Args    string
+}
+
+// Prediction is an instruction expected to leave unmanaged binaries in the image
+type Prediction struct {
+	Line        int
+	Kind        string
+	Instruction string
+	// Paths are the files, or the directories for extracted archives and copied trees, the instruction writes
+	Paths []string
+}
+
+// Matches reports whether the binary is one of the paths of the prediction or lies under one of them
+func (p Prediction) Matches(binary string) bool {
+	for _, x := range p.Paths {
+		if binary == x || strings.HasPrefix(binary, strings.TrimSuffix(x, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Parse reads the instructions of a Dockerfile
+func Parse(r io.Reader) ([]Instruction, error) {
+	var (
+		instructions []Instruction
+		current      strings.Builder
+		start        int
+	)
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if strings.HasPrefix(line, "#") || (line == "" && current.Len() == 0) {
+			continue
+		}
+		if current.Len() == 0 {
+			start = n
+		}
+		continued := strings.HasSuffix(line, `\`)
+		current.WriteString(strings.TrimSpace(strings.TrimSuffix(line, `\`)))
+		if continued {
+			current.WriteString(" ")
+			continue
+		}
+		if i := toInstruction(start, current.String()); i.Command != "" {
+			instructions = append(instructions, i)
+		}
+		current.Reset()
+	}
+	if current.Len() > 0 {
+		if i := toInstruction(start, current.String()); i.Command != "" {
+			instructions = append(instructions, i)
+		}
+	}
+	return instructions, s.Err()
+}
+
+// Load parses the Dockerfile of the given name
+func Load(name string) ([]Instruction, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	instructions, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", name, err)
+	}
+	return instructions, nil
+}
+
+func toInstruction(line int, text string) Instruction {
+	fields := strings.SplitN(strings.TrimSpace(text), " ", 2)
+	i := Instruction{Line: line, Command: strings.ToUpper(fields[0])}
+	if len(fields) == 2 {
+		i.Args = strings.TrimSpace(fields[1])
+	}
+	return i
+}
+
+// stage is a build stage, from its FROM instruction to the next one
+type stage struct {
+	name         string
+	base         string
+	instructions []Instruction
+}
+
+// Predict returns the instructions of the final stage, and of the stages it
+// is built from, that introduce binaries outside the package manager
+func Predict(instructions []Instruction) []Prediction {
+	stages := splitStages(instructions)
+	if len(stages) == 0 {
+		return nil
+	}
+	// the final image holds the files of the last stage and of the stages it is FROM
+	chain := []stage{stages[len(stages)-1]}
+	for seen := map[string]bool{}; ; {
+		base := chain[0].base
+		found := false
+		for _, st := range stages {
+			if st.name != "" && strings.EqualFold(st.name, base) && !seen[st.name] {
+				seen[st.name] = true
+				chain = append([]stage{st}, chain...)
+				found = true
+				break
+			}
+		}
+		if !found {
+			break
+		}
+	}
+	p := predictor{workdir: "/", env: make(map[string]string)}
+	for _, st := range chain {
+		for _, i := range st.instructions {
+			p.instruction(i)
+		}
+	}
+	return p.predictions
+}
+
+func splitStages(instructions []Instruction) []stage {
+	var stages []stage
+	for _, i := range instructions {
+		if i.Command == "FROM" {
+			fields := strings.Fields(i.Args)
+			var st stage
+			for len(fields) > 0 && strings.HasPrefix(fields[0], "--") {
+				fields = fields[1:]
+			}
+			if len(fields) > 0 {
+				st.base = fields[0]
+			}
+			if len(fields) == 3 && strings.EqualFold(fields[1], "AS") {
+				st.name = fields[2]
+			}
+			stages = append(stages, st)
+			continue
+		}
+		if len(stages) > 0 {
+			stages[len(stages)-1].instructions = append(stages[len(stages)-1].instructions, i)
+		}
+	}
+	return stages
+}
+
+// predictor follows the working directory and the environment of the final image stages
+type predictor struct {
+	workdir     string
+	env         map[string]string
+	predictions []Prediction
+}
+
+var (
+	variable   = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-[^}]*)?\}|\$([A-Za-z_][A-Za-z0-9_]*)`)
+	remote     = regexp.MustCompile(`^https?://`)
+	separators = regexp.MustCompile(`&&|\|\||;`)
+	executable = regexp.MustCompile(`^([ugoa]*\+[rwx]*x[rwx]*|0?[0-7]?[1357][0-7][0-7])$`)
+)
+
+// expand replaces the variables set by ENV and ARG, keeping the unknown ones
+func (p *predictor) expand(s string) string {
+	return variable.ReplaceAllStringFunc(s, func(v string) string {
+		m := variable.FindStringSubmatch(v)
+		name := m[1] + m[3]
+		if value, ok := p.env[name]; ok {
+			return value
+		}
+		if m[2] != "" {
+			return strings.TrimPrefix(m[2], ":-")
+		}
+		return v
+	})
+}
+
+// abs resolves a path against the working directory
+func (p *predictor) abs(name string) string {
+	return absPath(p.workdir, name)
+}
+
+// absPath resolves a path against a directory, keeping the trailing slash of directories
+func absPath(workdir, name string) string {
+	name = strings.Trim(name, `"'`)
+	dir := strings.HasSuffix(name, "/")
+	if !path.IsAbs(name) {
+		name = path.Join(workdir, name)
+	}
+	name = path.Clean(name)
+	if dir && name != "/" {
+		name += "/"
+	}
+	return name
+}
+
+// dirPath marks the path as a directory, "/" stays as is
+func dirPath(name string) string {
+	if strings.HasSuffix(name, "/") {
+		return name
+	}
+	return name + "/"
+}
+
+func (p *predictor) instruction(i Instruction) {
+	args := p.expand(i.Args)
+	switch i.Command {
+	case "WORKDIR":
+		p.workdir = strings.TrimSuffix(p.abs(args), "/")
+		if p.workdir == "" {
+			p.workdir = "/"
+		}
+	case "ENV", "ARG":
+		p.setVariables(i.Command, args)
+	case "ADD":
+		_, srcs, dest := copyArgs(args)
+		var paths []string
+		for _, src := range srcs {
+			if remote.MatchString(src) {
+				paths = append(paths, p.destination(dest, src, len(srcs) > 1))
+			}
+		}
+		if len(paths) > 0 {
+			p.add(i, AddURL, paths)
+		}
+	case "COPY":
+		flags, srcs, dest := copyArgs(args)
+		if flags["--from"] == "" || len(srcs) == 0 {
+			return
+		}
+		var paths []string
+		for _, src := range srcs {
+			paths = append(paths, p.destination(dest, src, len(srcs) > 1))
+		}
+		p.add(i, CopyFromStage, paths)
+	case "RUN":
+		p.run(i, args)
+	}
+}
+
+// setVariables records the variables of an ENV or ARG instruction, in the
+// key=value or the legacy "ENV key value" form
+func (p *predictor) setVariables(command, args string) {
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		return
+	}
+	if command == "ENV" && !strings.Contains(fields[0], "=") {
+		if kv := strings.SplitN(args, " ", 2); len(kv) == 2 {
+			p.env[kv[0]] = strings.Trim(strings.TrimSpace(kv[1]), `"'`)
+		}
+		return
+	}
+	for _, f := range fields {
+		kv := strings.SplitN(f, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		if _, set := p.env[kv[0]]; command == "ARG" && set {
+			continue
+		}
+		p.env[kv[0]] = strings.Trim(kv[1], `"'`)
+	}
+}
+
+// destination returns the path a COPY or ADD source is written to
+func (p *predictor) destination(dest, src string, many bool) string {
+	dest = p.abs(dest)
+	// the content of a source directory, or of many sources, lands in dest itself
+	if strings.HasSuffix(dest, "/") && !many && !strings.HasSuffix(src, "/") && !strings.ContainsAny(path.Base(src), "*?[") {
+		return path.Join(dest, path.Base(src))
+	}
+	return dest
+}
+
+// copyArgs splits the arguments of a COPY or ADD instruction, in the shell or the JSON form
+func copyArgs(args string) (map[string]string, []string, string) {
+	flags := make(map[string]string)
+	for strings.HasPrefix(args, "--") {
+		parts := strings.SplitN(args, " ", 2)
+		kv := strings.SplitN(parts[0], "=", 2)
+		if len(kv) == 2 {
+			flags[kv[0]] = kv[1]
+		} else {
+			flags[kv[0]] = "true"
+		}
+		args = ""
+		if len(parts) == 2 {
+			args = strings.TrimSpace(parts[1])
+		}
+	}
+	var fields []string
+	if strings.HasPrefix(args, "[") {
+		for _, f := range strings.Split(strings.Trim(args, "[]"), ",") {
+			fields = append(fields, strings.Trim(strings.TrimSpace(f), `"`))
+		}
+	} else {
+		fields = strings.Fields(args)
+	}
+	if len(fields) < 2 {
+		return flags, nil, ""
+	}
+	return flags, fields[:len(fields)-1], fields[len(fields)-1]
+}
+
+// run predicts the binaries of the curl and wget commands of a RUN
+// instruction: archives piped or later given to tar, and downloads made executable
+func (p *predictor) run(i Instruction, script string) {
+	if !strings.Contains(script, "curl") && !strings.Contains(script, "wget") {
+		return
+	}
+	var archives, executables []string
+	downloaded := false
+	workdir := p.workdir
+	for _, command := range separators.Split(script, -1) {
+		pipeline := strings.Split(command, "|")
+		for n, c := range pipeline {
+			args := strings.Fields(c)
+			if len(args) == 0 {
+				continue
+			}
+			switch path.Base(args[0]) {
+			case "cd":
+				if len(args) > 1 {
+					workdir = strings.TrimSuffix(absPath(workdir, args[1]), "/")
+					if workdir == "" {
+						workdir = "/"
+					}
+				}
+			case "curl", "wget":
+				downloaded = true
+			case "tar":
+				// an archive piped from curl or wget, or downloaded earlier in the instruction
+				if downloaded && (n > 0 || extracts(args)) {
+					archives = append(archives, dirPath(absPath(workdir, tarDir(args, workdir))))
+				}
+			case "unzip":
+				if downloaded {
+					archives = append(archives, dirPath(absPath(workdir, unzipDir(args, workdir))))
+				}
+			case "chmod":
+				if downloaded && len(args) > 2 && executable.MatchString(args[1]) {
+					for _, a := range args[2:] {
+						executables = append(executables, absPath(workdir, a))
+					}
+				}
+			}
+		}
+	}
+	if len(archives) > 0 {
+		p.add(i, DownloadArchive, archives)
+	}
+	if len(executables) > 0 {
+		p.add(i, DownloadExecutable, executables)
+	}
+}
+
+// extracts reports whether the tar arguments extract an archive
+func extracts(args []string) bool {
+	for _, a := range args[1:] {
+		// --extract, -xzf, or the old style xzf as first argument
+		if a == "--extract" || a == "--get" ||
+			(strings.HasPrefix(a, "-") && !strings.HasPrefix(a, "--") && strings.Contains(a, "x")) ||
+			(a == args[1] && strings.HasPrefix(a, "x")) {
+			return true
+		}
+	}
+	return false
+}
+
+// tarDir returns the directory tar extracts into
+func tarDir(args []string, workdir string) string {
+	for n, a := range args {
+		switch {
+		case (a == "-C" || a == "--directory") && n+1 < len(args):
+			return args[n+1]
+		case strings.HasPrefix(a, "--directory="):
+			return strings.TrimPrefix(a, "--directory=")
+		case strings.HasPrefix(a, "-C") && len(a) > 2:
+			return a[2:]
+		}
+	}
+	return workdir
+}
+
+// unzipDir returns the directory unzip extracts into
+func unzipDir(args []string, workdir string) string {
+	for n, a := range args {
+		if a == "-d" && n+1 < len(args) {
+			return args[n+1]
+		}
+	}
+	return workdir
+}
+
+func (p *predictor) add(i Instruction, kind string, paths []string) {
+	text := i.Command + " " + i.Args
+	if len(text) > maxInstruction {
+		text = text[:maxInstruction] + "..."
+	}
+	p.predictions = append(p.predictions, Prediction{Line: i.Line, Kind: kind, Instruction: text, Paths: paths})
+}
+
+// maxInstruction is the longest instruction text kept in a prediction
+const maxInstruction = 200
@@ -0,0 +1,93 @@
+package dockerfile
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const multiStage = `# syntax=docker/dockerfile:1
+FROM golang:1.17 AS builder
+WORKDIR /src
+RUN curl -fsSL https://example.com/protoc.tar.gz | tar -xz -C /usr/local
+RUN go build -o /out/app ./cmd/app
+
+FROM debian:bullseye-slim AS runtime
+ENV GOSU_VERSION=1.14 \
+    TINI_VERSION=v0.19.0
+ARG GOSU_VERSION=1.12
+RUN set -eux; \
+    apt-get update; \
+    wget -O /usr/local/bin/gosu "https://github.com/tianon/gosu/releases/download/${GOSU_VERSION}/gosu-amd64"; \
+    chmod +x /usr/local/bin/gosu
+ADD https://github.com/krallin/tini/releases/download/${TINI_VERSION}/tini /sbin/
+
+FROM runtime
+WORKDIR /opt
+RUN wget -q https://example.com/node.tar.xz && tar -xJf node.tar.xz -C node --strip-components=1
+COPY --from=builder /out/app /usr/local/bin/app
+COPY --from=builder /out/plugins/ /usr/lib/app/plugins/
+COPY scripts/entrypoint.sh /usr/local/bin/
+`
+
+func TestParse(t *testing.T) {
+	instructions, err := Parse(strings.NewReader(multiStage))
+	require.NoError(t, err)
+	require.Len(t, instructions, 15)
+	assert.Equal(t, Instruction{Line: 2, Command: "FROM", Args: "golang:1.17 AS builder"}, instructions[0])
+	assert.Equal(t, Instruction{Line: 8, Command: "ENV", Args: "GOSU_VERSION=1.14 TINI_VERSION=v0.19.0"}, instructions[5])
+	assert.Equal(t, 11, instructions[7].Line)
+	assert.Equal(t, "RUN", instructions[7].Command)
+}
+
+func TestPredict(t *testing.T) {
+	instructions, err := Parse(strings.NewReader(multiStage))
+	require.NoError(t, err)
+	predictions := Predict(instructions)
+
+	var got []Prediction
+	for _, p := range predictions {
+		got = append(got, Prediction{Line: p.Line, Kind: p.Kind, Paths: p.Paths})
+	}
+	assert.Equal(t, []Prediction{
+		{Line: 11, Kind: DownloadExecutable, Paths: []string{"/usr/local/bin/gosu"}},
+		{Line: 15, Kind: AddURL, Paths: []string{"/sbin/tini"}},
+		{Line: 19, Kind: DownloadArchive, Paths: []string{"/opt/node/"}},
+		{Line: 20, Kind: CopyFromStage, Paths: []string{"/usr/local/bin/app"}},
+		{Line: 21, Kind: CopyFromStage, Paths: []string{"/usr/lib/app/plugins/"}},
+	}, got)
+	assert.True(t, strings.HasPrefix(predictions[0].Instruction, "RUN set -eux;"))
+}
+
+func TestPredict_RootDirectory(t *testing.T) {
+	instructions, err := Parse(strings.NewReader(`FROM alpine:3.14
+RUN curl -fsSL https://example.com/tools.tar.gz | tar -xz -C /
+RUN wget -q https://example.com/jq.tgz && tar xzf jq.tgz
+RUN cd / && wget -q https://example.com/yq.zip && unzip yq.zip
+`))
+	require.NoError(t, err)
+	predictions := Predict(instructions)
+	require.Len(t, predictions, 3)
+	for _, p := range predictions {
+		assert.Equal(t, DownloadArchive, p.Kind)
+		assert.Equal(t, []string{"/"}, p.Paths)
+		assert.True(t, p.Matches("/usr/local/bin/tool"))
+	}
+}
+
+func TestPrediction_Matches(t *testing.T) {
+	p := Prediction{Paths: []string{"/opt/node/", "/usr/local/bin/gosu"}}
+	assert.True(t, p.Matches("/opt/node/bin/node"))
+	assert.True(t, p.Matches("/usr/local/bin/gosu"))
+	assert.False(t, p.Matches("/usr/local/bin/gosu-amd64"))
+	assert.False(t, p.Matches("/opt/nodejs"))
+}
+
+func TestCopyArgs(t *testing.T) {
+	flags, srcs, dest := copyArgs(`--from=builder --chown=app ["/out/a", "/out/b", "/usr/bin/"]`)
+	assert.Equal(t, map[string]string{"--from": "builder", "--chown": "app"}, flags)
+	assert.Equal(t, []string{"/out/a", "/out/b"}, srcs)
+	assert.Equal(t, "/usr/bin/", dest)
+}
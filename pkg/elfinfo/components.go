@@ -0,0 +1,231 @@
+package elfinfo
+
+import (
+	"bytes"
+	"compress/zlib"
+	"debug/elf"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// GoModule is a Go module linked into a binary
+type GoModule struct {
+	Path    string
+	Version string
+}
+
+// GoBuildInfo is the build information embedded by the Go toolchain
+type GoBuildInfo struct {
+	// GoVersion is the toolchain version, e.g. go1.15.2
+	GoVersion string
+	// Path is the package path of the main package
+	Path string
+	Main GoModule
+	Deps []GoModule
+}
+
+// RustPackage is a crate recorded by cargo-auditable
+type RustPackage struct {
+	Name    string
+	Version string
+	// Source is where the crate comes from, e.g. crates.io, git or local
+	Source string
+	// Kind is runtime or build
+	Kind string
+}
+
+var (
+	goBuildInfoMagic = []byte("\xff Go buildinf:")
+	// modinfo is wrapped in sentinels so that it can be found in the binary
+	modInfoStart = []byte("0w\xaf\x0c\x92t\x08\x02A\xe1\xc1\x07\xe6\xd6\x18\xe6")
+	modInfoEnd   = []byte("\xf92C1\x86\x18 r\x00\x82B\x10A\x16\xd8\xf2")
+)
+
+// flags of the Go build info header
+const (
+	goBuildInfoBigEndian = 0x1
+	goBuildInfoInline    = 0x2
+)
+
+// goBuildInfoSearch is how much of the first writable segment is searched for
+// the build info when the file has no section headers
+const goBuildInfoSearch = 64 * 1024
+
+// GoBuildInfo returns the build information of a Go binary, or nil when the
+// binary was not built by the Go toolchain
+func (info *Info) GoBuildInfo() *GoBuildInfo {
+	data := info.goBuildInfoData()
+	if data == nil {
+		return nil
+	}
+	ptrSize := int(data[14])
+	flags := data[15]
+	var version, mod string
+	if flags&goBuildInfoInline != 0 {
+		b := data[32:]
+		var ok bool
+		if version, b, ok = readVarString(b); !ok {
+			return nil
+		}
+		if mod, _, ok = readVarString(b); !ok {
+			return nil
+		}
+	} else {
+		if ptrSize != 4 && ptrSize != 8 {
+			return nil
+		}
+		var order binary.ByteOrder = binary.LittleEndian
+		if flags&goBuildInfoBigEndian != 0 {
+			order = binary.BigEndian
+		}
+		version = info.readGoString(order, ptrSize, readPointer(order, ptrSize, data[16:]))
+		mod = info.readGoString(order, ptrSize, readPointer(order, ptrSize, data[16+ptrSize:]))
+	}
+	if version == "" {
+		return nil
+	}
+	bi := &GoBuildInfo{GoVersion: version}
+	parseModInfo(bi, mod)
+	return bi
+}
+
+// goBuildInfoData returns the build info header and what follows it
+func (info *Info) goBuildInfoData() []byte {
+	f := info.file
+	if s := f.Section(".go.buildinfo"); s != nil {
+		if b, err := sectionData(s); err == nil && bytes.HasPrefix(b, goBuildInfoMagic) && len(b) >= 32 {
+			return b
+		}
+		return nil
+	}
+	for _, p := range f.Progs {
+		if p.Type != elf.PT_LOAD || p.Flags&elf.PF_W == 0 {
+			continue
+		}
+		size := p.Filesz
+		if size > goBuildInfoSearch {
+			size = goBuildInfoSearch
+		}
+		b, err := readAll(p.Open(), size)
+		if err != nil {
+			return nil
+		}
+		for i := 0; i+32 <= len(b); i += 16 {
+			if bytes.HasPrefix(b[i:], goBuildInfoMagic) {
+				return b[i:]
+			}
+		}
+	}
+	return nil
+}
+
+func readPointer(order binary.ByteOrder, ptrSize int, b []byte) uint64 {
+	if ptrSize == 4 {
+		return uint64(order.Uint32(b))
+	}
+	return order.Uint64(b)
+}
+
+// readGoString reads the Go string whose header is at addr
+func (info *Info) readGoString(order binary.ByteOrder, ptrSize int, addr uint64) string {
+	hdr := info.readAddress(addr, uint64(2*ptrSize))
+	if hdr == nil {
+		return ""
+	}
+	data, size := readPointer(order, ptrSize, hdr), readPointer(order, ptrSize, hdr[ptrSize:])
+	if size == 0 || size > 1<<20 {
+		return ""
+	}
+	return string(info.readAddress(data, size))
+}
+
+func readVarString(b []byte) (string, []byte, bool) {
+	n, l := binary.Uvarint(b)
+	if l <= 0 || n > uint64(len(b)-l) {
+		return "", nil, false
+	}
+	return string(b[l : l+int(n)]), b[l+int(n):], true
+}
+
+func parseModInfo(bi *GoBuildInfo, mod string) {
+	if len(mod) >= len(modInfoStart)+len(modInfoEnd) &&
+		strings.HasPrefix(mod, string(modInfoStart)) && strings.HasSuffix(mod, string(modInfoEnd)) {
+		mod = mod[len(modInfoStart) : len(mod)-len(modInfoEnd)]
+	}
+	for _, line := range strings.Split(mod, "\n") {
+		f := strings.Split(line, "\t")
+		switch {
+		case len(f) >= 2 && f[0] == "path":
+			bi.Path = f[1]
+		case len(f) >= 3 && f[0] == "mod":
+			bi.Main = GoModule{Path: f[1], Version: f[2]}
+		case len(f) >= 3 && f[0] == "dep":
+			bi.Deps = append(bi.Deps, GoModule{Path: f[1], Version: f[2]})
+		case len(f) >= 3 && f[0] == "=>" && len(bi.Deps) > 0:
+			// the replacement of the previous dependency is what was built
+			bi.Deps[len(bi.Deps)-1] = GoModule{Path: f[1], Version: f[2]}
+		}
+	}
+}
+
+// ReadOnlyData returns the content of the .rodata sections, where compilers
+// store string literals, or nil when the file has no section headers
+func (info *Info) ReadOnlyData() []byte {
+	var data []byte
+	for _, s := range info.file.Sections {
+		if s.Type != elf.SHT_PROGBITS || !strings.HasPrefix(s.Name, ".rodata") {
+			continue
+		}
+		if b, err := sectionData(s); err == nil {
+			data = append(data, b...)
+		}
+	}
+	return data
+}
+
+// RustPackages returns the crates recorded by cargo-auditable in the .dep-v0
+// section, or nil when the binary was not built with it
+func (info *Info) RustPackages() []RustPackage {
+	s := info.file.Section(".dep-v0")
+	if s == nil {
+		return nil
+	}
+	b, err := sectionData(s)
+	if err != nil {
+		return nil
+	}
+	r, err := zlib.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil
+	}
+	defer r.Close()
+	// a few kilobytes compress into a stream inflating past any memory, only
+	// maxRead bytes are inflated and a larger dependency list is left out
+	b, err = ioutil.ReadAll(io.LimitReader(r, maxRead+1))
+	if err != nil || len(b) > maxRead {
+		return nil
+	}
+	var deps struct {
+		Packages []struct {
+			Name    string
+			Version string
+			Source  string
+			Kind    string
+		}
+	}
+	if err = json.Unmarshal(b, &deps); err != nil {
+		return nil
+	}
+	var packages []RustPackage
+	for _, p := range deps.Packages {
+		kind := p.Kind
+		if kind == "" {
+			kind = "runtime"
+		}
+		packages = append(packages, RustPackage{Name: p.Name, Version: p.Version, Source: p.Source, Kind: kind})
+	}
+	return packages
+}
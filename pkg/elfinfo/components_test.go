@@ -0,0 +1,147 @@
+package elfinfo
+
+import (
+	"bytes"
+	"compress/zlib"
+	"debug/elf"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aquasecurity/binfinder/pkg/elfinfo/elftest"
+)
+
+const testModInfo = "path\tgithub.com/tianon/gosu\n" +
+	"mod\tgithub.com/tianon/gosu\t(devel)\t\n" +
+	"dep\tgithub.com/opencontainers/runc\tv1.0.0-rc10\th1:abc=\n" +
+	"dep\tgolang.org/x/sys\tv0.0.0-20191115151921-52ab43148777\th1:def=\n" +
+	"=>\tgolang.org/x/sys\tv0.0.0-20200930185726-fdedc70b468f\th1:ghi=\n"
+
+func varString(s string) []byte {
+	b := make([]byte, binary.MaxVarintLen64)
+	return append(b[:binary.PutUvarint(b, uint64(len(s)))], s...)
+}
+
+func buildInfoHeader(flags byte) []byte {
+	hdr := make([]byte, 32)
+	copy(hdr, goBuildInfoMagic)
+	hdr[14] = 8
+	hdr[15] = flags
+	return hdr
+}
+
+func readInfo(t *testing.T, f elftest.File) *Info {
+	info, err := Read(bytes.NewReader(f.Bytes()))
+	require.NoError(t, err)
+	return info
+}
+
+func TestInfo_GoBuildInfo(t *testing.T) {
+	mod := string(modInfoStart) + testModInfo + string(modInfoEnd)
+	want := &GoBuildInfo{
+		GoVersion: "go1.18.3",
+		Path:      "github.com/tianon/gosu",
+		Main:      GoModule{Path: "github.com/tianon/gosu", Version: "(devel)"},
+		Deps: []GoModule{
+			{Path: "github.com/opencontainers/runc", Version: "v1.0.0-rc10"},
+			{Path: "golang.org/x/sys", Version: "v0.0.0-20200930185726-fdedc70b468f"},
+		},
+	}
+
+	t.Run("inline", func(t *testing.T) {
+		data := append(buildInfoHeader(goBuildInfoInline), varString("go1.18.3")...)
+		data = append(data, varString(mod)...)
+		info := readInfo(t, elftest.File{Sections: []elftest.Section{
+			{Name: ".go.buildinfo", Type: elf.SHT_PROGBITS, Flags: elf.SHF_ALLOC | elf.SHF_WRITE, Data: data},
+		}})
+		defer info.Close()
+		assert.Equal(t, want, info.GoBuildInfo())
+	})
+
+	t.Run("pointers", func(t *testing.T) {
+		// the section is laid out as: header, version and modinfo string headers, string data
+		const headers = 32
+		build := func(base uint64) elftest.File {
+			data := buildInfoHeader(0)
+			binary.LittleEndian.PutUint64(data[16:], base+headers)
+			binary.LittleEndian.PutUint64(data[24:], base+headers+16)
+			strs := make([]byte, 32)
+			binary.LittleEndian.PutUint64(strs[0:], base+headers+32)
+			binary.LittleEndian.PutUint64(strs[8:], uint64(len("go1.15.2")))
+			binary.LittleEndian.PutUint64(strs[16:], base+headers+32+uint64(len("go1.15.2")))
+			binary.LittleEndian.PutUint64(strs[24:], uint64(len(mod)))
+			data = append(append(append(data, strs...), "go1.15.2"...), mod...)
+			return elftest.File{Sections: []elftest.Section{
+				{Name: ".go.buildinfo", Type: elf.SHT_PROGBITS, Flags: elf.SHF_ALLOC | elf.SHF_WRITE, Data: data},
+			}}
+		}
+		// find where the section lands, addresses are file offsets in elftest files
+		f, err := elf.NewFile(bytes.NewReader(build(0).Bytes()))
+		require.NoError(t, err)
+		base := f.Section(".go.buildinfo").Offset
+
+		info := readInfo(t, build(base))
+		defer info.Close()
+		got := info.GoBuildInfo()
+		require.NotNil(t, got)
+		assert.Equal(t, "go1.15.2", got.GoVersion)
+		assert.Equal(t, want.Deps, got.Deps)
+	})
+
+	t.Run("not go", func(t *testing.T) {
+		info := readInfo(t, elftest.File{})
+		defer info.Close()
+		assert.Nil(t, info.GoBuildInfo())
+	})
+}
+
+func TestInfo_RustPackages(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := zlib.NewWriter(buf)
+	_, _ = w.Write([]byte(`{"packages":[
+		{"name":"ripgrep","version":"13.0.0","source":"local","root":true,"dependencies":[1,2]},
+		{"name":"regex","version":"1.5.4","source":"crates.io"},
+		{"name":"cc","version":"1.0.72","source":"crates.io","kind":"build"}
+	]}`))
+	require.NoError(t, w.Close())
+
+	info := readInfo(t, elftest.File{Sections: []elftest.Section{
+		{Name: ".dep-v0", Type: elf.SHT_PROGBITS, Data: buf.Bytes()},
+	}})
+	defer info.Close()
+	assert.Equal(t, []RustPackage{
+		{Name: "ripgrep", Version: "13.0.0", Source: "local", Kind: "runtime"},
+		{Name: "regex", Version: "1.5.4", Source: "crates.io", Kind: "runtime"},
+		{Name: "cc", Version: "1.0.72", Source: "crates.io", Kind: "build"},
+	}, info.RustPackages())
+
+	info = readInfo(t, elftest.File{})
+	defer info.Close()
+	assert.Nil(t, info.RustPackages())
+
+	// a decompression bomb inflating past maxRead
+	buf.Reset()
+	w = zlib.NewWriter(buf)
+	zeros := make([]byte, 1<<20)
+	for i := 0; i <= maxRead>>20; i++ {
+		_, _ = w.Write(zeros)
+	}
+	require.NoError(t, w.Close())
+	info = readInfo(t, elftest.File{Sections: []elftest.Section{
+		{Name: ".dep-v0", Type: elf.SHT_PROGBITS, Data: buf.Bytes()},
+	}})
+	defer info.Close()
+	assert.Nil(t, info.RustPackages())
+}
+
+func TestInfo_ReadOnlyData(t *testing.T) {
+	info := readInfo(t, elftest.File{Sections: []elftest.Section{
+		{Name: ".rodata", Type: elf.SHT_PROGBITS, Flags: elf.SHF_ALLOC, Data: []byte("OpenSSL 1.1.1g  21 Apr 2020\x00")},
+		{Name: ".text", Type: elf.SHT_PROGBITS, Flags: elf.SHF_ALLOC | elf.SHF_EXECINSTR, Data: []byte{0xc3}},
+		{Name: ".rodata.str1.1", Type: elf.SHT_PROGBITS, Flags: elf.SHF_ALLOC, Data: []byte("libcurl/7.68.0\x00")},
+	}})
+	defer info.Close()
+	assert.Equal(t, []byte("OpenSSL 1.1.1g  21 Apr 2020\x00libcurl/7.68.0\x00"), info.ReadOnlyData())
+}
@@ -0,0 +1,372 @@
+package elfinfo
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"math"
+	"strings"
+)
+
+// RELRO levels
+const (
+	RelroFull    = "full"
+	RelroPartial = "partial"
+	RelroNone    = "none"
+)
+
+// DT_FLAGS_1 values, not defined by debug/elf before go1.21
+const (
+	df1Now = 0x1
+	df1PIE = 0x08000000
+)
+
+// Hardening holds checksec style properties of an ELF binary
+type Hardening struct {
+	PIE     bool
+	RELRO   string
+	NX      bool
+	Canary  bool
+	Fortify bool
+}
+
+// Info is what binfinder extracts from an ELF file
+type Info struct {
+	Type        elf.Type
+	Interpreter string
+	Needed      []string
+	RPath       []string
+	RunPath     []string
+	// BuildID is the GNU build ID in hex, or the Go build ID of Go binaries without one
+	BuildID   string
+	Hardening Hardening
+
+	file    *elf.File
+	dynamic []dynEntry
+	dynstr  []byte
+	symbols map[string]bool
+}
+
+type dynEntry struct {
+	tag elf.DynTag
+	val uint64
+}
+
+var canarySymbols = []string{"__stack_chk_fail", "__stack_chk_guard", "__intel_security_cookie"}
+
+// Read parses the ELF file in r
+func Read(r io.ReaderAt) (*Info, error) {
+	f, err := elf.NewFile(r)
+	if err != nil {
+		return nil, err
+	}
+	info := &Info{Type: f.Type, file: f}
+	info.readDynamic()
+	info.readSymbols()
+	info.Interpreter = info.interpreter()
+	info.Needed = info.dynStrings(elf.DT_NEEDED)
+	info.RPath = splitPath(info.dynStrings(elf.DT_RPATH))
+	info.RunPath = splitPath(info.dynStrings(elf.DT_RUNPATH))
+	info.BuildID = info.buildID()
+	info.Hardening = info.hardening()
+	return info, nil
+}
+
+// Close releases the underlying ELF file
+func (info *Info) Close() error {
+	return info.file.Close()
+}
+
+func (info *Info) readDynamic() {
+	f := info.file
+	var data []byte
+	if s := f.SectionByType(elf.SHT_DYNAMIC); s != nil {
+		data, _ = sectionData(s)
+	}
+	if data == nil {
+		for _, p := range f.Progs {
+			if p.Type == elf.PT_DYNAMIC {
+				data, _ = readAll(p.Open(), p.Filesz)
+				break
+			}
+		}
+	}
+	if data == nil {
+		return
+	}
+	entrySize := 16
+	if f.Class == elf.ELFCLASS32 {
+		entrySize = 8
+	}
+	var strtab, strsz uint64
+	for len(data) >= entrySize {
+		var e dynEntry
+		if f.Class == elf.ELFCLASS32 {
+			e = dynEntry{tag: elf.DynTag(int32(f.ByteOrder.Uint32(data))), val: uint64(f.ByteOrder.Uint32(data[4:]))}
+		} else {
+			e = dynEntry{tag: elf.DynTag(int64(f.ByteOrder.Uint64(data))), val: f.ByteOrder.Uint64(data[8:])}
+		}
+		data = data[entrySize:]
+		if e.tag == elf.DT_NULL {
+			break
+		}
+		switch e.tag {
+		case elf.DT_STRTAB:
+			strtab = e.val
+		case elf.DT_STRSZ:
+			strsz = e.val
+		}
+		info.dynamic = append(info.dynamic, e)
+	}
+	if s := f.Section(".dynstr"); s != nil {
+		info.dynstr, _ = sectionData(s)
+	} else if strtab != 0 && strsz != 0 {
+		info.dynstr = info.readAddress(strtab, strsz)
+	}
+}
+
+// readAddress reads size bytes at the virtual address addr of a loaded segment
+func (info *Info) readAddress(addr, size uint64) []byte {
+	if size > maxRead || addr+size < addr {
+		return nil
+	}
+	for _, p := range info.file.Progs {
+		// the offset and the end are compared without adding the untrusted header values
+		if p.Type != elf.PT_LOAD || addr < p.Vaddr || addr-p.Vaddr > p.Filesz || size > p.Filesz-(addr-p.Vaddr) {
+			continue
+		}
+		off := addr - p.Vaddr
+		if off > math.MaxInt64 {
+			return nil
+		}
+		b, err := readAll(io.NewSectionReader(p, int64(off), int64(size)), size)
+		if err != nil {
+			return nil
+		}
+		return b
+	}
+	return nil
+}
+
+// maxRead caps the bytes read from a segment or an address range whose size
+// comes from the ELF headers, which a crafted file sets at will
+const maxRead = 16 << 20
+
+// readAll reads size bytes from r, size capped at maxRead. The buffer grows
+// with the bytes actually read, a size larger than the file costs no memory.
+func readAll(r io.Reader, size uint64) ([]byte, error) {
+	if size > maxRead {
+		size = maxRead
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(r, int64(size)))
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(b)) < size {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return b, nil
+}
+
+// sectionData reads the content of a section, its size capped at maxRead:
+// Section.Data allocates the size of the section header before reading it
+func sectionData(s *elf.Section) ([]byte, error) {
+	return readAll(s.Open(), s.Size)
+}
+
+// symbolTableWithin reports whether the symbol table of the type and its
+// string table are at most maxRead bytes, debug/elf reads them whole
+func (info *Info) symbolTableWithin(typ elf.SectionType) bool {
+	s := info.file.SectionByType(typ)
+	if s == nil {
+		return false
+	}
+	if s.Size > maxRead || int(s.Link) >= len(info.file.Sections) {
+		return false
+	}
+	return info.file.Sections[s.Link].Size <= maxRead
+}
+
+func (info *Info) readSymbols() {
+	info.symbols = make(map[string]bool)
+	if info.symbolTableWithin(elf.SHT_DYNSYM) {
+		if syms, err := info.file.DynamicSymbols(); err == nil {
+			for _, s := range syms {
+				info.symbols[s.Name] = true
+			}
+		}
+	}
+	if info.symbolTableWithin(elf.SHT_SYMTAB) {
+		if syms, err := info.file.Symbols(); err == nil {
+			for _, s := range syms {
+				info.symbols[s.Name] = true
+			}
+		}
+	}
+}
+
+// dynValues returns the values of the dynamic entries with the given tag
+func (info *Info) dynValues(tag elf.DynTag) []uint64 {
+	var values []uint64
+	for _, e := range info.dynamic {
+		if e.tag == tag {
+			values = append(values, e.val)
+		}
+	}
+	return values
+}
+
+// dynStrings returns the strings referenced by the dynamic entries with the given tag
+func (info *Info) dynStrings(tag elf.DynTag) []string {
+	var values []string
+	for _, v := range info.dynValues(tag) {
+		if v >= uint64(len(info.dynstr)) {
+			continue
+		}
+		s := info.dynstr[v:]
+		if i := bytes.IndexByte(s, 0); i >= 0 {
+			s = s[:i]
+		}
+		values = append(values, string(s))
+	}
+	return values
+}
+
+func (info *Info) interpreter() string {
+	p, ok := info.hasProg(elf.PT_INTERP)
+	if !ok {
+		return ""
+	}
+	b, err := readAll(p.Open(), p.Filesz)
+	if err != nil {
+		return ""
+	}
+	return string(bytes.TrimRight(b, "\x00"))
+}
+
+// note types of the build ID notes
+const (
+	ntGNUBuildID = 3
+	ntGoBuildID  = 4
+)
+
+// buildID reads the build ID notes from the note sections, or from the
+// PT_NOTE segments when the file has no section headers
+func (info *Info) buildID() string {
+	var notes [][]byte
+	for _, s := range info.file.Sections {
+		if s.Type == elf.SHT_NOTE {
+			if b, err := sectionData(s); err == nil {
+				notes = append(notes, b)
+			}
+		}
+	}
+	if len(info.file.Sections) == 0 {
+		for _, p := range info.file.Progs {
+			if p.Type == elf.PT_NOTE {
+				if b, err := readAll(p.Open(), p.Filesz); err == nil {
+					notes = append(notes, b)
+				}
+			}
+		}
+	}
+	var goID string
+	for _, b := range notes {
+		for len(b) >= 12 {
+			order := info.file.ByteOrder
+			namesz, descsz, typ := order.Uint32(b), order.Uint32(b[4:]), order.Uint32(b[8:])
+			nameEnd := 12 + align4(namesz)
+			descEnd := nameEnd + align4(descsz)
+			if nameEnd > uint64(len(b)) || nameEnd+uint64(descsz) > uint64(len(b)) {
+				break
+			}
+			name := string(bytes.TrimRight(b[12:12+uint64(namesz)], "\x00"))
+			desc := b[nameEnd : nameEnd+uint64(descsz)]
+			switch {
+			case name == "GNU" && typ == ntGNUBuildID:
+				return hex.EncodeToString(desc)
+			case name == "Go" && typ == ntGoBuildID:
+				goID = string(bytes.TrimRight(desc, "\x00"))
+			}
+			if descEnd > uint64(len(b)) {
+				break
+			}
+			b = b[descEnd:]
+		}
+	}
+	return goID
+}
+
+func align4(n uint32) uint64 {
+	return (uint64(n) + 3) &^ 3
+}
+
+// splitPath splits colon separated search path values into their entries
+func splitPath(values []string) []string {
+	var entries []string
+	for _, v := range values {
+		for _, e := range strings.Split(v, ":") {
+			if e != "" {
+				entries = append(entries, e)
+			}
+		}
+	}
+	return entries
+}
+
+func (info *Info) hasProg(t elf.ProgType) (*elf.Prog, bool) {
+	for _, p := range info.file.Progs {
+		if p.Type == t {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+func (info *Info) hardening() Hardening {
+	var h Hardening
+
+	_, hasInterp := info.hasProg(elf.PT_INTERP)
+	var flags, flags1 uint64
+	for _, v := range info.dynValues(elf.DT_FLAGS) {
+		flags |= v
+	}
+	for _, v := range info.dynValues(elf.DT_FLAGS_1) {
+		flags1 |= v
+	}
+	h.PIE = info.Type == elf.ET_DYN && (hasInterp || flags1&df1PIE != 0)
+
+	h.RELRO = RelroNone
+	if _, ok := info.hasProg(elf.PT_GNU_RELRO); ok {
+		h.RELRO = RelroPartial
+		if flags&uint64(elf.DF_BIND_NOW) != 0 || flags1&df1Now != 0 ||
+			len(info.dynValues(elf.DT_BIND_NOW)) > 0 {
+			h.RELRO = RelroFull
+		}
+	}
+
+	if p, ok := info.hasProg(elf.PT_GNU_STACK); ok {
+		h.NX = p.Flags&elf.PF_X == 0
+	}
+
+	for _, s := range canarySymbols {
+		if info.symbols[s] {
+			h.Canary = true
+		}
+	}
+	for s := range info.symbols {
+		if IsFortified(s) {
+			h.Fortify = true
+			break
+		}
+	}
+	return h
+}
+
+// IsFortified reports whether the symbol is a _FORTIFY_SOURCE checked libc function
+func IsFortified(symbol string) bool {
+	return strings.HasPrefix(symbol, "__") && strings.HasSuffix(symbol, "_chk") &&
+		symbol != "__stack_chk_fail" && symbol != "__stack_chk_guard"
+}
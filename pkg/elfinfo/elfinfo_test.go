@@ -0,0 +1,307 @@
+package elfinfo
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aquasecurity/binfinder/pkg/elfinfo/elftest"
+)
+
+func TestRead_Hardening(t *testing.T) {
+	testCases := []struct {
+		name string
+		file elftest.File
+		want Hardening
+	}{
+		{
+			name: "fully hardened PIE",
+			file: elftest.File{
+				Type:           elf.ET_DYN,
+				Interpreter:    "/lib64/ld-linux-x86-64.so.2",
+				Needed:         []string{"libc.so.6"},
+				Dynamic:        map[elf.DynTag]uint64{elf.DT_FLAGS: uint64(elf.DF_BIND_NOW), elf.DT_FLAGS_1: df1Now | df1PIE},
+				Relro:          true,
+				Stack:          elftest.Flags(elf.PF_R | elf.PF_W),
+				DynamicSymbols: []string{"__stack_chk_fail", "__memcpy_chk", "printf"},
+			},
+			want: Hardening{PIE: true, RELRO: RelroFull, NX: true, Canary: true, Fortify: true},
+		},
+		{
+			name: "partial relro, executable stack",
+			file: elftest.File{
+				Type:           elf.ET_EXEC,
+				Interpreter:    "/lib64/ld-linux-x86-64.so.2",
+				Needed:         []string{"libc.so.6"},
+				Relro:          true,
+				Stack:          elftest.Flags(elf.PF_R | elf.PF_W | elf.PF_X),
+				DynamicSymbols: []string{"printf", "__stack_chk_fail"},
+			},
+			want: Hardening{RELRO: RelroPartial, Canary: true},
+		},
+		{
+			name: "static PIE",
+			file: elftest.File{
+				Type:    elf.ET_DYN,
+				Dynamic: map[elf.DynTag]uint64{elf.DT_FLAGS_1: df1PIE},
+				Stack:   elftest.Flags(elf.PF_R | elf.PF_W),
+				Symbols: []string{"__stack_chk_guard", "__sprintf_chk"},
+			},
+			want: Hardening{PIE: true, RELRO: RelroNone, NX: true, Canary: true, Fortify: true},
+		},
+		{
+			name: "static executable without GNU_STACK",
+			file: elftest.File{Type: elf.ET_EXEC, Symbols: []string{"main"}},
+			want: Hardening{RELRO: RelroNone},
+		},
+		{
+			name: "no section headers",
+			file: elftest.File{
+				Type:             elf.ET_DYN,
+				Interpreter:      "/lib/ld-musl-x86_64.so.1",
+				Dynamic:          map[elf.DynTag]uint64{elf.DT_BIND_NOW: 0},
+				Relro:            true,
+				Stack:            elftest.Flags(elf.PF_R | elf.PF_W),
+				DynamicSymbols:   []string{"__stack_chk_fail"},
+				NoSectionHeaders: true,
+			},
+			want: Hardening{PIE: true, RELRO: RelroFull, NX: true},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			info, err := Read(bytes.NewReader(tc.file.Bytes()))
+			require.NoError(t, err)
+			defer info.Close()
+			assert.Equal(t, tc.want, info.Hardening)
+		})
+	}
+}
+
+func TestRead_Dynamic(t *testing.T) {
+	f := elftest.File{
+		Type:        elf.ET_DYN,
+		Interpreter: "/lib64/ld-linux-x86-64.so.2",
+		Needed:      []string{"libssl.so.1.1", "libc.so.6"},
+		RPath:       "/opt/app/lib",
+		RunPath:     "$ORIGIN/../lib::/tmp/lib",
+	}
+	for _, noSections := range []bool{false, true} {
+		f.NoSectionHeaders = noSections
+		info, err := Read(bytes.NewReader(f.Bytes()))
+		require.NoError(t, err)
+		assert.Equal(t, "/lib64/ld-linux-x86-64.so.2", info.Interpreter)
+		assert.Equal(t, []string{"libssl.so.1.1", "libc.so.6"}, info.Needed)
+		assert.Equal(t, []string{"/opt/app/lib"}, info.RPath)
+		assert.Equal(t, []string{"$ORIGIN/../lib", "/tmp/lib"}, info.RunPath)
+		info.Close()
+	}
+
+	info, err := Read(bytes.NewReader(elftest.File{}.Bytes()))
+	require.NoError(t, err)
+	assert.Empty(t, info.Interpreter)
+	assert.Empty(t, info.Needed)
+}
+
+func TestRead_BuildID(t *testing.T) {
+	gnu := elftest.Note(".note.gnu.build-id", "GNU", 3, []byte{0xde, 0xad, 0xbe, 0xef, 0x01})
+	goNote := elftest.Note(".note.go.buildid", "Go", 4, []byte("abc/def"))
+
+	info, err := Read(bytes.NewReader(elftest.File{Sections: []elftest.Section{goNote, gnu}}.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, "deadbeef01", info.BuildID)
+	info.Close()
+
+	info, err = Read(bytes.NewReader(elftest.File{Sections: []elftest.Section{goNote}}.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, "abc/def", info.BuildID)
+	info.Close()
+
+	info, err = Read(bytes.NewReader(elftest.File{}.Bytes()))
+	require.NoError(t, err)
+	assert.Empty(t, info.BuildID)
+	info.Close()
+}
+
+func TestRead_NotELF(t *testing.T) {
+	_, err := Read(bytes.NewReader([]byte("#!/bin/sh\n")))
+	assert.Error(t, err)
+}
+
+// hugeSegments returns an ELF header followed by program headers claiming
+// segments of 1TB the file does not hold
+func hugeSegments(types ...elf.ProgType) []byte {
+	buf := &bytes.Buffer{}
+	hdr := elf.Header64{Type: uint16(elf.ET_DYN), Machine: uint16(elf.EM_X86_64), Version: uint32(elf.EV_CURRENT),
+		Phoff: 64, Ehsize: 64, Phentsize: 56, Phnum: uint16(len(types))}
+	copy(hdr.Ident[:], elf.ELFMAG)
+	hdr.Ident[elf.EI_CLASS], hdr.Ident[elf.EI_DATA], hdr.Ident[elf.EI_VERSION] =
+		byte(elf.ELFCLASS64), byte(elf.ELFDATA2LSB), byte(elf.EV_CURRENT)
+	_ = binary.Write(buf, binary.LittleEndian, hdr)
+	for _, typ := range types {
+		_ = binary.Write(buf, binary.LittleEndian, elf.Prog64{Type: uint32(typ), Flags: uint32(elf.PF_R | elf.PF_W),
+			Filesz: 1 << 40, Memsz: 1 << 40})
+	}
+	return buf.Bytes()
+}
+
+func TestRead_HugeSegments(t *testing.T) {
+	for _, typ := range []elf.ProgType{elf.PT_DYNAMIC, elf.PT_INTERP, elf.PT_NOTE, elf.PT_LOAD} {
+		b := hugeSegments(typ)
+		require.Len(t, b, 120)
+		info, err := Read(bytes.NewReader(b))
+		require.NoError(t, err, typ)
+		assert.Empty(t, info.Interpreter, typ)
+		assert.Empty(t, info.BuildID, typ)
+		assert.Nil(t, info.GoBuildInfo(), typ)
+		info.Close()
+	}
+
+	info, err := Read(bytes.NewReader(hugeSegments(elf.PT_LOAD)))
+	require.NoError(t, err)
+	assert.Nil(t, info.readAddress(1<<63, 1<<63), "overflowing range")
+	assert.Nil(t, info.readAddress(0, 1<<40), "range beyond maxRead")
+	assert.Nil(t, info.readAddress(100, 64), "range beyond the file")
+	info.Close()
+}
+
+// hugeSections returns an ELF header followed by a section header of each
+// type claiming a terabyte of content
+func hugeSections(types ...elf.SectionType) []byte {
+	buf := &bytes.Buffer{}
+	hdr := elf.Header64{Type: uint16(elf.ET_DYN), Machine: uint16(elf.EM_X86_64), Version: uint32(elf.EV_CURRENT),
+		Shoff: 64, Ehsize: 64, Shentsize: 64, Shnum: uint16(len(types) + 1)}
+	copy(hdr.Ident[:], elf.ELFMAG)
+	hdr.Ident[elf.EI_CLASS], hdr.Ident[elf.EI_DATA], hdr.Ident[elf.EI_VERSION] =
+		byte(elf.ELFCLASS64), byte(elf.ELFDATA2LSB), byte(elf.EV_CURRENT)
+	_ = binary.Write(buf, binary.LittleEndian, hdr)
+	_ = binary.Write(buf, binary.LittleEndian, elf.Section64{})
+	for _, typ := range types {
+		_ = binary.Write(buf, binary.LittleEndian, elf.Section64{Type: uint32(typ), Off: 64, Size: 1 << 40})
+	}
+	return buf.Bytes()
+}
+
+func TestRead_HugeSections(t *testing.T) {
+	for _, typ := range []elf.SectionType{elf.SHT_DYNAMIC, elf.SHT_NOTE, elf.SHT_DYNSYM, elf.SHT_SYMTAB} {
+		info, err := Read(bytes.NewReader(hugeSections(typ)))
+		require.NoError(t, err, typ)
+		assert.Empty(t, info.BuildID, typ)
+		assert.Empty(t, info.symbols, typ)
+		info.Close()
+	}
+
+	b := hugeSections(elf.SHT_PROGBITS)
+	f, err := elf.NewFile(bytes.NewReader(b))
+	require.NoError(t, err)
+	_, err = sectionData(f.Sections[1])
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+}
+
+func TestIsFortified(t *testing.T) {
+	assert.True(t, IsFortified("__printf_chk"))
+	assert.False(t, IsFortified("__stack_chk_fail"))
+	assert.False(t, IsFortified("printf"))
+}
+
+func TestInfo_Packing(t *testing.T) {
+	random := make([]byte, 4096)
+	_, _ = rand.New(rand.NewSource(1)).Read(random)
+	text := bytes.Repeat([]byte{0x55, 0x48, 0x89, 0xe5, 0x5d, 0xc3}, 700)
+
+	testCases := []struct {
+		name           string
+		file           elftest.File
+		want           Packing
+		wantSuspicious bool
+	}{
+		{
+			name: "regular binary",
+			file: elftest.File{Sections: []elftest.Section{
+				{Name: ".text", Type: elf.SHT_PROGBITS, Flags: elf.SHF_ALLOC | elf.SHF_EXECINSTR, Data: text},
+			}},
+		},
+		{
+			name: "compressed data is not suspicious by itself",
+			file: elftest.File{Sections: []elftest.Section{
+				{Name: ".text", Type: elf.SHT_PROGBITS, Flags: elf.SHF_ALLOC | elf.SHF_EXECINSTR, Data: text},
+				{Name: ".rodata", Type: elf.SHT_PROGBITS, Flags: elf.SHF_ALLOC, Data: random},
+			}},
+			want: Packing{HighEntropySections: []SectionEntropy{{Name: ".rodata", Entropy: 7.96}}},
+		},
+		{
+			name: "encrypted code",
+			file: elftest.File{Sections: []elftest.Section{
+				{Name: ".text", Type: elf.SHT_PROGBITS, Flags: elf.SHF_ALLOC | elf.SHF_EXECINSTR, Data: random},
+			}},
+			want:           Packing{HighEntropySections: []SectionEntropy{{Name: ".text", Entropy: 7.96, Executable: true}}},
+			wantSuspicious: true,
+		},
+		{
+			name: "UPX",
+			file: elftest.File{NoSectionHeaders: true, Sections: []elftest.Section{
+				{Name: "", Type: elf.SHT_PROGBITS, Data: append([]byte("UPX!"), random...)},
+			}},
+			want: Packing{Packer: "UPX", NoSectionHeaders: true, HighEntropySections: []SectionEntropy{
+				{Name: "LOAD[0]", Entropy: 7.91, Executable: true}}},
+			wantSuspicious: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b := tc.file.Bytes()
+			info, err := Read(bytes.NewReader(b))
+			require.NoError(t, err)
+			defer info.Close()
+			got := info.Packing(bytes.NewReader(b), int64(len(b)))
+			assert.Equal(t, tc.want, got)
+			assert.Equal(t, tc.wantSuspicious, got.Suspicious())
+		})
+	}
+}
+
+func TestInfo_Size(t *testing.T) {
+	debugInfo := elftest.Section{Name: ".debug_info", Type: elf.SHT_PROGBITS, Data: make([]byte, 1000)}
+	testCases := []struct {
+		name string
+		file elftest.File
+		want Size
+	}{
+		{
+			name: "stripped",
+			file: elftest.File{},
+			want: Size{Stripped: true},
+		},
+		{
+			name: "symbol table",
+			file: elftest.File{Symbols: []string{"main", "helper"}},
+			want: Size{StrippableBytes: 85},
+		},
+		{
+			name: "debug info",
+			file: elftest.File{Symbols: []string{"main", "helper"}, Sections: []elftest.Section{debugInfo}},
+			want: Size{DebugInfo: true, StrippableBytes: 1085},
+		},
+		{
+			name: "separate debug info",
+			file: elftest.File{Sections: []elftest.Section{debugInfo}},
+			want: Size{Stripped: true, DebugInfo: true, StrippableBytes: 1000},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b := tc.file.Bytes()
+			info, err := Read(bytes.NewReader(b))
+			require.NoError(t, err)
+			defer info.Close()
+			tc.want.Bytes = int64(len(b))
+			assert.Equal(t, tc.want, info.Size(int64(len(b))))
+		})
+	}
+}
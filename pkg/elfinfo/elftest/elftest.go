@@ -0,0 +1,296 @@
+// Package elftest builds small 64-bit little-endian ELF files for tests
+package elftest
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+)
+
+const (
+	headerSize  = 64
+	progSize    = 56
+	sectionSize = 64
+	symbolSize  = 24
+	dynSize     = 16
+)
+
+// Section is an additional section written into the file
+type Section struct {
+	Name  string
+	Type  elf.SectionType
+	Flags elf.SectionFlag
+	Data  []byte
+}
+
+// File describes the ELF file to build
+type File struct {
+	Type    elf.Type
+	Machine elf.Machine
+
+	Interpreter string
+	Needed      []string
+	SOName      string
+	RPath       string
+	RunPath     string
+	// Dynamic are extra entries of the dynamic section
+	Dynamic map[elf.DynTag]uint64
+
+	// Relro adds a PT_GNU_RELRO segment
+	Relro bool
+	// Stack adds a PT_GNU_STACK segment with the given flags
+	Stack *elf.ProgFlag
+
+	DynamicSymbols []string
+	Symbols        []string
+	Sections       []Section
+
+	// NoSectionHeaders strips the section header table
+	NoSectionHeaders bool
+}
+
+type blob struct {
+	offset uint64
+	data   []byte
+}
+
+type layout struct {
+	buf bytes.Buffer
+}
+
+func (l *layout) add(data []byte) blob {
+	for l.buf.Len()%8 != 0 {
+		l.buf.WriteByte(0)
+	}
+	b := blob{offset: uint64(l.buf.Len()), data: data}
+	l.buf.Write(data)
+	return b
+}
+
+type strtab struct {
+	data []byte
+}
+
+func newStrtab() *strtab {
+	return &strtab{data: []byte{0}}
+}
+
+func (s *strtab) add(str string) uint32 {
+	off := uint32(len(s.data))
+	s.data = append(s.data, str...)
+	s.data = append(s.data, 0)
+	return off
+}
+
+func symtab(names []string, strings *strtab) []byte {
+	buf := &bytes.Buffer{}
+	buf.Write(make([]byte, symbolSize))
+	for _, n := range names {
+		sym := elf.Sym64{
+			Name:  strings.add(n),
+			Info:  elf.ST_INFO(elf.STB_GLOBAL, elf.STT_FUNC),
+			Shndx: uint16(elf.SHN_UNDEF),
+		}
+		_ = binary.Write(buf, binary.LittleEndian, sym)
+	}
+	return buf.Bytes()
+}
+
+type sectionHeader struct {
+	name string
+	hdr  elf.Section64
+}
+
+// Bytes returns the encoded ELF file
+func (f File) Bytes() []byte {
+	if f.Type == elf.ET_NONE {
+		f.Type = elf.ET_EXEC
+	}
+	if f.Machine == elf.EM_NONE {
+		f.Machine = elf.EM_X86_64
+	}
+	progs := 1 // PT_LOAD covering the whole file
+	dynamic := f.Interpreter != "" || len(f.Needed) > 0 || f.SOName != "" || f.RPath != "" ||
+		f.RunPath != "" || len(f.Dynamic) > 0 || len(f.DynamicSymbols) > 0
+	if f.Interpreter != "" {
+		progs++
+	}
+	if dynamic {
+		progs++
+	}
+	if f.Relro {
+		progs++
+	}
+	if f.Stack != nil {
+		progs++
+	}
+
+	l := &layout{}
+	l.buf.Write(make([]byte, headerSize+progs*progSize))
+
+	var sections []sectionHeader
+	var interp, dyn blob
+	if f.Interpreter != "" {
+		interp = l.add(append([]byte(f.Interpreter), 0))
+		sections = append(sections, sectionHeader{name: ".interp", hdr: elf.Section64{
+			Type: uint32(elf.SHT_PROGBITS), Flags: uint64(elf.SHF_ALLOC), Off: interp.offset,
+			Addr: interp.offset, Size: uint64(len(interp.data)), Addralign: 1}})
+	}
+	if dynamic {
+		dynstr := newStrtab()
+		dynsymData := symtab(f.DynamicSymbols, dynstr)
+		var entries [][2]uint64
+		for _, n := range f.Needed {
+			entries = append(entries, [2]uint64{uint64(elf.DT_NEEDED), uint64(dynstr.add(n))})
+		}
+		if f.SOName != "" {
+			entries = append(entries, [2]uint64{uint64(elf.DT_SONAME), uint64(dynstr.add(f.SOName))})
+		}
+		if f.RPath != "" {
+			entries = append(entries, [2]uint64{uint64(elf.DT_RPATH), uint64(dynstr.add(f.RPath))})
+		}
+		if f.RunPath != "" {
+			entries = append(entries, [2]uint64{uint64(elf.DT_RUNPATH), uint64(dynstr.add(f.RunPath))})
+		}
+		for _, tag := range []elf.DynTag{elf.DT_FLAGS, elf.DT_FLAGS_1, elf.DT_BIND_NOW} {
+			if v, ok := f.Dynamic[tag]; ok {
+				entries = append(entries, [2]uint64{uint64(tag), v})
+			}
+		}
+		dynstrBlob := l.add(dynstr.data)
+		dynsymBlob := l.add(dynsymData)
+		entries = append(entries,
+			[2]uint64{uint64(elf.DT_STRTAB), dynstrBlob.offset},
+			[2]uint64{uint64(elf.DT_STRSZ), uint64(len(dynstr.data))},
+			[2]uint64{uint64(elf.DT_SYMTAB), dynsymBlob.offset},
+			[2]uint64{uint64(elf.DT_NULL), 0})
+		dynData := &bytes.Buffer{}
+		for _, e := range entries {
+			_ = binary.Write(dynData, binary.LittleEndian, e)
+		}
+		dyn = l.add(dynData.Bytes())
+
+		dynstrIndex := uint32(len(sections) + 1)
+		sections = append(sections,
+			sectionHeader{name: ".dynstr", hdr: elf.Section64{Type: uint32(elf.SHT_STRTAB),
+				Flags: uint64(elf.SHF_ALLOC), Off: dynstrBlob.offset, Addr: dynstrBlob.offset,
+				Size: uint64(len(dynstrBlob.data)), Addralign: 1}},
+			sectionHeader{name: ".dynsym", hdr: elf.Section64{Type: uint32(elf.SHT_DYNSYM),
+				Flags: uint64(elf.SHF_ALLOC), Off: dynsymBlob.offset, Addr: dynsymBlob.offset,
+				Size: uint64(len(dynsymBlob.data)), Link: dynstrIndex, Info: 1, Addralign: 8, Entsize: symbolSize}},
+			sectionHeader{name: ".dynamic", hdr: elf.Section64{Type: uint32(elf.SHT_DYNAMIC),
+				Flags: uint64(elf.SHF_ALLOC | elf.SHF_WRITE), Off: dyn.offset, Addr: dyn.offset,
+				Size: uint64(len(dyn.data)), Link: dynstrIndex, Addralign: 8, Entsize: dynSize}})
+	}
+	if len(f.Symbols) > 0 {
+		str := newStrtab()
+		symData := symtab(f.Symbols, str)
+		strBlob := l.add(str.data)
+		symBlob := l.add(symData)
+		strIndex := uint32(len(sections) + 1)
+		sections = append(sections,
+			sectionHeader{name: ".strtab", hdr: elf.Section64{Type: uint32(elf.SHT_STRTAB),
+				Off: strBlob.offset, Size: uint64(len(strBlob.data)), Addralign: 1}},
+			sectionHeader{name: ".symtab", hdr: elf.Section64{Type: uint32(elf.SHT_SYMTAB),
+				Off: symBlob.offset, Size: uint64(len(symBlob.data)), Link: strIndex, Info: 1,
+				Addralign: 8, Entsize: symbolSize}})
+	}
+	for _, s := range f.Sections {
+		b := l.add(s.Data)
+		addr := uint64(0)
+		if s.Flags&elf.SHF_ALLOC != 0 {
+			addr = b.offset
+		}
+		sections = append(sections, sectionHeader{name: s.Name, hdr: elf.Section64{Type: uint32(s.Type),
+			Flags: uint64(s.Flags), Off: b.offset, Addr: addr, Size: uint64(len(s.Data)), Addralign: 1}})
+	}
+
+	shstr := newStrtab()
+	for i := range sections {
+		sections[i].hdr.Name = shstr.add(sections[i].name)
+	}
+	shstrName := shstr.add(".shstrtab")
+	shstrBlob := l.add(shstr.data)
+	sections = append(sections, sectionHeader{name: ".shstrtab", hdr: elf.Section64{Name: shstrName,
+		Type: uint32(elf.SHT_STRTAB), Off: shstrBlob.offset, Size: uint64(len(shstrBlob.data)), Addralign: 1}})
+
+	var shoff uint64
+	if !f.NoSectionHeaders {
+		for l.buf.Len()%8 != 0 {
+			l.buf.WriteByte(0)
+		}
+		shoff = uint64(l.buf.Len())
+		_ = binary.Write(&l.buf, binary.LittleEndian, elf.Section64{})
+		for _, s := range sections {
+			_ = binary.Write(&l.buf, binary.LittleEndian, s.hdr)
+		}
+	}
+	size := uint64(l.buf.Len())
+
+	out := l.buf.Bytes()
+	hdr := elf.Header64{
+		Type:      uint16(f.Type),
+		Machine:   uint16(f.Machine),
+		Version:   uint32(elf.EV_CURRENT),
+		Phoff:     headerSize,
+		Shoff:     shoff,
+		Ehsize:    headerSize,
+		Phentsize: progSize,
+		Phnum:     uint16(progs),
+		Shentsize: sectionSize,
+	}
+	copy(hdr.Ident[:], elf.ELFMAG)
+	hdr.Ident[elf.EI_CLASS] = byte(elf.ELFCLASS64)
+	hdr.Ident[elf.EI_DATA] = byte(elf.ELFDATA2LSB)
+	hdr.Ident[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+	if !f.NoSectionHeaders {
+		hdr.Shnum = uint16(len(sections) + 1)
+		hdr.Shstrndx = uint16(len(sections))
+	}
+
+	progHdrs := []elf.Prog64{{Type: uint32(elf.PT_LOAD), Flags: uint32(elf.PF_R | elf.PF_X),
+		Filesz: size, Memsz: size, Align: 0x1000}}
+	if f.Interpreter != "" {
+		progHdrs = append(progHdrs, elf.Prog64{Type: uint32(elf.PT_INTERP), Flags: uint32(elf.PF_R),
+			Off: interp.offset, Vaddr: interp.offset, Filesz: uint64(len(interp.data)), Memsz: uint64(len(interp.data)), Align: 1})
+	}
+	if dynamic {
+		progHdrs = append(progHdrs, elf.Prog64{Type: uint32(elf.PT_DYNAMIC), Flags: uint32(elf.PF_R | elf.PF_W),
+			Off: dyn.offset, Vaddr: dyn.offset, Filesz: uint64(len(dyn.data)), Memsz: uint64(len(dyn.data)), Align: 8})
+	}
+	if f.Relro {
+		progHdrs = append(progHdrs, elf.Prog64{Type: uint32(elf.PT_GNU_RELRO), Flags: uint32(elf.PF_R),
+			Off: dyn.offset, Vaddr: dyn.offset, Filesz: uint64(len(dyn.data)), Memsz: uint64(len(dyn.data)), Align: 1})
+	}
+	if f.Stack != nil {
+		progHdrs = append(progHdrs, elf.Prog64{Type: uint32(elf.PT_GNU_STACK), Flags: uint32(*f.Stack), Align: 16})
+	}
+
+	head := &bytes.Buffer{}
+	_ = binary.Write(head, binary.LittleEndian, hdr)
+	for _, p := range progHdrs {
+		_ = binary.Write(head, binary.LittleEndian, p)
+	}
+	copy(out, head.Bytes())
+	return out
+}
+
+// Flags returns a pointer to the given program flags, for File.Stack
+func Flags(f elf.ProgFlag) *elf.ProgFlag {
+	return &f
+}
+
+// Note returns a SHT_NOTE section holding a single note
+func Note(section, name string, typ uint32, desc []byte) Section {
+	pad := func(b []byte) []byte {
+		for len(b)%4 != 0 {
+			b = append(b, 0)
+		}
+		return b
+	}
+	data := &bytes.Buffer{}
+	_ = binary.Write(data, binary.LittleEndian, []uint32{uint32(len(name) + 1), uint32(len(desc)), typ})
+	data.Write(pad(append([]byte(name), 0)))
+	data.Write(pad(append([]byte{}, desc...)))
+	return Section{Name: section, Type: elf.SHT_NOTE, Flags: elf.SHF_ALLOC, Data: data.Bytes()}
+}
@@ -0,0 +1,144 @@
+package elfinfo
+
+import (
+	"bytes"
+	"debug/elf"
+	"fmt"
+	"io"
+	"math"
+)
+
+// HighEntropy is the Shannon entropy, in bits per byte, above which the content
+// of a section is considered compressed or encrypted
+const HighEntropy = 7.2
+
+// minEntropySize is the smallest section or segment whose entropy is meaningful
+const minEntropySize = 512
+
+// signatureWindow is how much of the start and of the end of the file is
+// searched for packer signatures
+const signatureWindow = 64 * 1024
+
+// packerSignatures are byte strings left in the files by known ELF packers
+var packerSignatures = []struct {
+	packer    string
+	signature []byte
+}{
+	{"UPX", []byte("UPX!")},
+	{"UPX", []byte("This file is packed with the UPX executable packer")},
+	{"burneye", []byte("TEEE burneye")},
+}
+
+// SectionEntropy is the entropy of a section, or of a loadable segment when
+// the file has no section headers
+type SectionEntropy struct {
+	Name       string
+	Entropy    float64
+	Executable bool `json:",omitempty"`
+}
+
+// Packing holds the signs that a binary was packed or obfuscated
+type Packing struct {
+	Packer              string           `json:",omitempty"`
+	NoSectionHeaders    bool             `json:",omitempty"`
+	HighEntropySections []SectionEntropy `json:",omitempty"`
+}
+
+// Suspicious reports whether the binary is likely packed: a packer signature,
+// no section headers or executable content that looks compressed
+func (p Packing) Suspicious() bool {
+	if p.Packer != "" || p.NoSectionHeaders {
+		return true
+	}
+	for _, s := range p.HighEntropySections {
+		if s.Executable {
+			return true
+		}
+	}
+	return false
+}
+
+// Packing looks for packer signatures and high entropy content in the ELF file
+// of size bytes in r, from which info was read
+func (info *Info) Packing(r io.ReaderAt, size int64) Packing {
+	p := Packing{Packer: findPacker(r, size)}
+	f := info.file
+	if len(f.Sections) == 0 {
+		p.NoSectionHeaders = true
+		for i, prog := range f.Progs {
+			if prog.Type != elf.PT_LOAD || prog.Filesz < minEntropySize {
+				continue
+			}
+			e := entropy(prog.Open())
+			if e >= HighEntropy {
+				p.HighEntropySections = append(p.HighEntropySections, SectionEntropy{
+					Name: fmt.Sprintf("LOAD[%d]", i), Entropy: round(e), Executable: prog.Flags&elf.PF_X != 0})
+			}
+		}
+		return p
+	}
+	for _, s := range f.Sections {
+		if s.Type == elf.SHT_NOBITS || s.Size < minEntropySize {
+			continue
+		}
+		e := entropy(s.Open())
+		if e >= HighEntropy {
+			p.HighEntropySections = append(p.HighEntropySections, SectionEntropy{
+				Name: s.Name, Entropy: round(e), Executable: s.Flags&elf.SHF_EXECINSTR != 0})
+		}
+	}
+	return p
+}
+
+func findPacker(r io.ReaderAt, size int64) string {
+	windows := [][2]int64{{0, signatureWindow}, {size - signatureWindow, size}}
+	for _, w := range windows {
+		if w[0] < 0 {
+			w[0] = 0
+		}
+		if w[1] > size {
+			w[1] = size
+		}
+		b := make([]byte, w[1]-w[0])
+		n, _ := r.ReadAt(b, w[0])
+		for _, s := range packerSignatures {
+			if bytes.Contains(b[:n], s.signature) {
+				return s.packer
+			}
+		}
+	}
+	return ""
+}
+
+// entropy returns the Shannon entropy of the content of r in bits per byte
+func entropy(r io.Reader) float64 {
+	var counts [256]int64
+	var total int64
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(buf)
+		for _, c := range buf[:n] {
+			counts[c]++
+		}
+		total += int64(n)
+		if err != nil {
+			break
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	var e float64
+	for _, c := range counts {
+		if c == 0 {
+			continue
+		}
+		p := float64(c) / float64(total)
+		e -= p * math.Log2(p)
+	}
+	return e
+}
+
+func round(f float64) float64 {
+	return math.Round(f*100) / 100
+}
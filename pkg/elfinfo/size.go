@@ -0,0 +1,41 @@
+package elfinfo
+
+import (
+	"debug/elf"
+	"strings"
+)
+
+// Size is how many bytes an ELF file takes and how much of it only serves debugging
+type Size struct {
+	Bytes int64
+	// Stripped is false when the file keeps its symbol table
+	Stripped bool
+	// DebugInfo is true when the file carries DWARF sections
+	DebugInfo bool
+	// StrippableBytes is the size of the symbol table and debug sections `strip` would remove
+	StrippableBytes int64 `json:",omitempty"`
+}
+
+// Size returns the size accounting of the file of fileSize bytes
+func (info *Info) Size(fileSize int64) Size {
+	s := Size{Bytes: fileSize, Stripped: true}
+	for _, section := range info.file.Sections {
+		switch {
+		case section.Type == elf.SHT_SYMTAB:
+			s.Stripped = false
+		case isDebugSection(section.Name):
+			s.DebugInfo = true
+		case section.Name == ".strtab":
+		default:
+			continue
+		}
+		if section.Type != elf.SHT_NOBITS {
+			s.StrippableBytes += int64(section.FileSize)
+		}
+	}
+	return s
+}
+
+func isDebugSection(name string) bool {
+	return strings.HasPrefix(name, ".debug_") || strings.HasPrefix(name, ".zdebug_")
+}
@@ -0,0 +1,127 @@
+package layerindex
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Image is the layer digest chain of an image
+type Image struct {
+	Image  string
+	Layers []string
+	// Reference is true for the images indexed from the reference list rather than scanned
+	Reference bool `json:",omitempty"`
+}
+
+// Match is the image with the longest layer chain another image is built on
+type Match struct {
+	Image string
+	// Aliases are the other indexed images with the same layers, e.g. other tags
+	Aliases []string `json:",omitempty"`
+	// Layers is the number of layers shared with the image
+	Layers int
+}
+
+// Index holds the layer chains of the scanned and reference images, one JSON file per image
+type Index struct {
+	dir string
+
+	mu     sync.RWMutex
+	images map[string]Image
+}
+
+// Open loads the index stored in dir, creating the directory if needed
+func Open(dir string) (*Index, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	ix := &Index{dir: dir, images: make(map[string]Image)}
+	names, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		b, err := ioutil.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		var img Image
+		if err = json.Unmarshal(b, &img); err != nil {
+			return nil, fmt.Errorf("%v: invalid layer index: %v", name, err)
+		}
+		ix.images[img.Image] = img
+	}
+	return ix, nil
+}
+
+// FileName returns the name of the index file of the image
+func FileName(image string) string {
+	return strings.ReplaceAll(image, "/", "-") + ".json"
+}
+
+// Add stores the layers of an image, replacing those of a previous scan
+func (ix *Index) Add(img Image) error {
+	b, err := json.MarshalIndent(img, "", " ")
+	if err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(filepath.Join(ix.dir, FileName(img.Image)), b, 0644); err != nil {
+		return err
+	}
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.images[img.Image] = img
+	return nil
+}
+
+// Size returns the number of indexed images
+func (ix *Index) Size() int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return len(ix.images)
+}
+
+// Lookup returns the indexed image, other than the image itself, whose
+// layers are the longest prefix of the layers, or nil
+func (ix *Index) Lookup(image string, layers []string) *Match {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	var names []string
+	for name := range ix.images {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var m *Match
+	for _, name := range names {
+		candidate := ix.images[name]
+		if name == image || !IsPrefix(candidate.Layers, layers) {
+			continue
+		}
+		switch {
+		case m == nil || len(candidate.Layers) > m.Layers:
+			m = &Match{Image: name, Layers: len(candidate.Layers)}
+		case len(candidate.Layers) == m.Layers:
+			m.Aliases = append(m.Aliases, name)
+		}
+	}
+	return m
+}
+
+// IsPrefix reports whether base are the first layers, leaving at least one layer added on top of them
+func IsPrefix(base, layers []string) bool {
+	if len(base) == 0 || len(base) >= len(layers) {
+		return false
+	}
+	for i := range base {
+		if base[i] != layers[i] {
+			return false
+		}
+	}
+	return true
+}
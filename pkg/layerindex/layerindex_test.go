@@ -0,0 +1,42 @@
+package layerindex
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndex(t *testing.T) {
+	dir, err := ioutil.TempDir("", "TestIndex-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	ix, err := Open(dir)
+	require.NoError(t, err)
+	require.NoError(t, ix.Add(Image{Image: "debian:bullseye", Layers: []string{"a"}, Reference: true}))
+	require.NoError(t, ix.Add(Image{Image: "python:3.9", Layers: []string{"a", "b", "c"}}))
+	require.NoError(t, ix.Add(Image{Image: "python:3.9-bullseye", Layers: []string{"a", "b", "c"}}))
+	require.NoError(t, ix.Add(Image{Image: "library/app:1", Layers: []string{"a", "b", "c", "d"}}))
+	assert.FileExists(t, filepath.Join(dir, "library-app:1.json"))
+
+	// reopened from disk
+	ix, err = Open(dir)
+	require.NoError(t, err)
+	assert.Equal(t, 4, ix.Size())
+	assert.Equal(t, &Match{Image: "python:3.9", Aliases: []string{"python:3.9-bullseye"}, Layers: 3},
+		ix.Lookup("library/app:1", []string{"a", "b", "c", "d"}))
+	assert.Equal(t, &Match{Image: "debian:bullseye", Layers: 1}, ix.Lookup("python:3.9", []string{"a", "b", "c"}))
+	assert.Nil(t, ix.Lookup("debian:bullseye", []string{"a"}))
+	assert.Nil(t, ix.Lookup("redis", []string{"x", "y"}))
+}
+
+func TestIsPrefix(t *testing.T) {
+	assert.True(t, IsPrefix([]string{"a", "b"}, []string{"a", "b", "c"}))
+	assert.False(t, IsPrefix([]string{"a", "b"}, []string{"a", "b"}))
+	assert.False(t, IsPrefix([]string{"a", "c"}, []string{"a", "b", "c"}))
+	assert.False(t, IsPrefix(nil, []string{"a"}))
+}
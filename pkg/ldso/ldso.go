@@ -0,0 +1,475 @@
+package ldso
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/aquasecurity/binfinder/pkg/elfinfo"
+	"github.com/aquasecurity/binfinder/pkg/rootfs"
+)
+
+const (
+	PreloadFile = "/etc/ld.so.preload"
+	ConfFile    = "/etc/ld.so.conf"
+)
+
+// Kinds of library search entries
+const (
+	KindRPath   = "RPATH"
+	KindRunPath = "RUNPATH"
+	KindPreload = "ld.so.preload"
+	KindConf    = "ld.so.conf"
+)
+
+// Reasons a library search entry is a hijack risk
+const (
+	ReasonWorldWritable = "world-writable"
+	ReasonMissing       = "missing"
+	ReasonUnmanaged     = "unmanaged"
+	ReasonRelative      = "relative"
+)
+
+// Finding is a library search entry that lets an attacker inject code
+type Finding struct {
+	// Source is the binary or configuration file the entry comes from
+	Source string
+	Kind   string
+	Entry  string
+	Reason string
+	// Path is the world-writable location when it is a parent of Entry
+	Path string `json:",omitempty"`
+}
+
+// Auditor checks library search entries against an image filesystem
+type Auditor struct {
+	FS *rootfs.FS
+	// Managed reports whether a file or directory was installed by a package
+	Managed func(name string) bool
+}
+
+// ParsePreload returns the libraries listed in an ld.so.preload file
+func ParsePreload(data []byte) []string {
+	var libs []string
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for s.Scan() {
+		line := s.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		libs = append(libs, strings.FieldsFunc(line, func(r rune) bool {
+			return r == ' ' || r == '\t' || r == ':'
+		})...)
+	}
+	return libs
+}
+
+// ParseConf returns the directories and include patterns of an ld.so.conf file
+func ParseConf(data []byte) (dirs []string, includes []string) {
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for s.Scan() {
+		line := s.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] == "include" {
+			includes = append(includes, fields[1:]...)
+			continue
+		}
+		if fields[0] == "hwcap" {
+			continue
+		}
+		for _, f := range fields {
+			dirs = append(dirs, strings.Split(f, ":")...)
+		}
+	}
+	return dirs, includes
+}
+
+// ExpandOrigin substitutes $ORIGIN in a search entry with the directory of the binary
+func ExpandOrigin(entry, binary string) string {
+	origin := path.Dir(binary)
+	entry = strings.Replace(entry, "${ORIGIN}", origin, -1)
+	return strings.Replace(entry, "$ORIGIN", origin, -1)
+}
+
+// ConfDirs returns the directories configured in ld.so.conf, following includes,
+// with the ld.so.conf file each directory comes from
+func (a Auditor) ConfDirs() (dirs []string, sources []string) {
+	seen := make(map[string]bool)
+	var read func(name string, depth int)
+	read = func(name string, depth int) {
+		if seen[name] || depth > 8 {
+			return
+		}
+		seen[name] = true
+		data, err := a.FS.ReadFile(name)
+		if err != nil {
+			return
+		}
+		d, includes := ParseConf(data)
+		for _, dir := range d {
+			dirs = append(dirs, dir)
+			sources = append(sources, name)
+		}
+		for _, pattern := range includes {
+			if !path.IsAbs(pattern) {
+				pattern = path.Join(path.Dir(name), pattern)
+			}
+			for _, match := range a.glob(pattern) {
+				read(match, depth+1)
+			}
+		}
+	}
+	read(ConfFile, 0)
+	return dirs, sources
+}
+
+func (a Auditor) glob(pattern string) []string {
+	var matches []string
+	for _, name := range a.FS.ReadDir(path.Dir(pattern)) {
+		if ok, _ := path.Match(path.Base(pattern), path.Base(name)); ok {
+			matches = append(matches, name)
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+// Binary checks the RPATH and RUNPATH entries of the binary at name
+func (a Auditor) Binary(name string, rpath, runpath []string) []Finding {
+	var findings []Finding
+	for _, e := range rpath {
+		findings = append(findings, a.check(name, KindRPath, e, ExpandOrigin(e, name))...)
+	}
+	for _, e := range runpath {
+		findings = append(findings, a.check(name, KindRunPath, e, ExpandOrigin(e, name))...)
+	}
+	return findings
+}
+
+// Preload checks the libraries listed in /etc/ld.so.preload
+func (a Auditor) Preload() []Finding {
+	data, err := a.FS.ReadFile(PreloadFile)
+	if err != nil {
+		return nil
+	}
+	var findings []Finding
+	for _, lib := range ParsePreload(data) {
+		findings = append(findings, a.check(PreloadFile, KindPreload, lib, lib)...)
+	}
+	return findings
+}
+
+// Conf checks the directories configured in ld.so.conf and its includes. The
+// missing directories of configuration files installed by packages are left
+// out: distributions configure directories such as /usr/local/lib/x86_64-linux-gnu
+// that only exist once something is installed there.
+func (a Auditor) Conf() []Finding {
+	dirs, sources := a.ConfDirs()
+	var findings []Finding
+	for i, dir := range dirs {
+		for _, f := range a.check(sources[i], KindConf, dir, dir) {
+			if f.Reason == ReasonMissing && a.Managed != nil && a.Managed(sources[i]) {
+				continue
+			}
+			findings = append(findings, f)
+		}
+	}
+	return findings
+}
+
+// check reports the reasons why the resolved entry p is a hijack risk
+func (a Auditor) check(source, kind, entry, p string) []Finding {
+	finding := Finding{Source: source, Kind: kind, Entry: entry}
+	if !path.IsAbs(p) {
+		finding.Reason = ReasonRelative
+		return []Finding{finding}
+	}
+	p = path.Clean(p)
+	if writable := a.worldWritable(p); writable != "" {
+		f := finding
+		f.Reason = ReasonWorldWritable
+		if writable != p {
+			f.Path = writable
+		}
+		return []Finding{f}
+	}
+	resolved, err := a.FS.Resolve(p)
+	if err != nil || !a.FS.Exists(resolved) {
+		finding.Reason = ReasonMissing
+		return []Finding{finding}
+	}
+	if a.Managed != nil && !a.Managed(p) && !a.Managed(resolved) {
+		finding.Reason = ReasonUnmanaged
+		return []Finding{finding}
+	}
+	return nil
+}
+
+// worldWritable returns p or the first of its parent directories that anyone
+// can write to, or an empty string
+func (a Auditor) worldWritable(p string) string {
+	var candidates []string
+	for dir := p; ; dir = path.Dir(dir) {
+		candidates = append([]string{dir}, candidates...)
+		if dir == "/" {
+			break
+		}
+	}
+	for _, c := range candidates {
+		e, ok := a.FS.Stat(c)
+		if !ok {
+			continue
+		}
+		if e.Header.Mode&0002 != 0 {
+			return c
+		}
+	}
+	return ""
+}
+
+// C libraries
+const (
+	LibcGlibc  = "glibc"
+	LibcMusl   = "musl"
+	LibcUclibc = "uclibc"
+)
+
+// Reasons a binary cannot run against the C library of the image
+const (
+	ReasonIncompatible       = "incompatible"
+	ReasonMissingInterpreter = "missing-interpreter"
+)
+
+// LibcMismatch is a binary linked against a C library the image does not provide
+type LibcMismatch struct {
+	Path        string
+	Interpreter string
+	Libc        string
+	ImageLibc   string
+	Reason      string
+	// Unresolved are the needed libraries not found in the image
+	Unresolved []string `json:",omitempty"`
+}
+
+// Libc returns the C library a dynamically linked binary was built against,
+// or an empty string for static binaries and unknown loaders
+func Libc(interpreter string, needed []string) string {
+	base := path.Base(interpreter)
+	switch {
+	case strings.HasPrefix(base, "ld-musl-"):
+		return LibcMusl
+	case strings.HasPrefix(base, "ld-uClibc"):
+		return LibcUclibc
+	case strings.HasPrefix(base, "ld-linux"), strings.HasPrefix(base, "ld64.so."), base == "ld.so.1":
+		return LibcGlibc
+	}
+	for _, n := range needed {
+		switch {
+		case strings.HasPrefix(n, "libc.musl-"):
+			return LibcMusl
+		case n == "libc.so.6":
+			return LibcGlibc
+		case strings.HasPrefix(n, "libc.so.0"):
+			return LibcUclibc
+		}
+	}
+	return ""
+}
+
+// Resolver finds shared libraries the way the dynamic linker of the image does
+type Resolver struct {
+	FS *rootfs.FS
+	// Dirs are the system library directories searched after RPATH and RUNPATH
+	Dirs []string
+
+	libraries map[string]library
+}
+
+var defaultDirs = map[string][]string{
+	LibcGlibc:  {"/lib64", "/usr/lib64", "/lib", "/usr/lib"},
+	LibcMusl:   {"/lib", "/usr/local/lib", "/usr/lib"},
+	LibcUclibc: {"/lib", "/usr/lib"},
+}
+
+// NewResolver returns a resolver using the library search path configured
+// in the image for the given C library
+func NewResolver(fs *rootfs.FS, libc string) *Resolver {
+	r := &Resolver{FS: fs}
+	if libc == LibcMusl {
+		for _, name := range fs.ReadDir("/etc") {
+			if !strings.HasPrefix(path.Base(name), "ld-musl-") || !strings.HasSuffix(name, ".path") {
+				continue
+			}
+			data, err := fs.ReadFile(name)
+			if err != nil {
+				continue
+			}
+			r.Dirs = append(r.Dirs, strings.FieldsFunc(string(data), func(c rune) bool {
+				return c == ':' || c == '\n' || c == ' ' || c == '\t'
+			})...)
+		}
+		if len(r.Dirs) > 0 {
+			return r
+		}
+	} else {
+		r.Dirs, _ = Auditor{FS: fs}.ConfDirs()
+	}
+	r.Dirs = append(r.Dirs, defaultDirs[libc]...)
+	if len(r.Dirs) == 0 {
+		r.Dirs = defaultDirs[LibcGlibc]
+	}
+	return r
+}
+
+// Resolve returns the path of the needed library lib of the binary at name
+func (r *Resolver) Resolve(lib string, name string, rpath, runpath []string) (string, bool) {
+	if strings.Contains(lib, "/") {
+		p := ExpandOrigin(lib, name)
+		return p, r.FS.Exists(p)
+	}
+	var dirs []string
+	if len(runpath) == 0 {
+		dirs = append(dirs, rpath...)
+	}
+	dirs = append(dirs, runpath...)
+	for i, d := range dirs {
+		dirs[i] = ExpandOrigin(d, name)
+	}
+	dirs = append(dirs, r.Dirs...)
+	for _, d := range dirs {
+		if !path.IsAbs(d) {
+			continue
+		}
+		p := path.Join(d, lib)
+		if e, ok := r.FS.Stat(p); ok && e.Header.Typeflag != tar.TypeDir {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// CheckLibc reports a binary whose C library differs from the image C library
+// or whose program interpreter is missing from the image
+func (r *Resolver) CheckLibc(name, imageLibc, interpreter string, needed, rpath, runpath []string) *LibcMismatch {
+	libc := Libc(interpreter, needed)
+	m := &LibcMismatch{Path: name, Interpreter: interpreter, Libc: libc, ImageLibc: imageLibc}
+	switch {
+	case interpreter != "" && !r.FS.Exists(interpreter):
+		m.Reason = ReasonMissingInterpreter
+	case libc != "" && imageLibc != "" && libc != imageLibc:
+		m.Reason = ReasonIncompatible
+	default:
+		return nil
+	}
+	for _, lib := range needed {
+		if _, ok := r.Resolve(lib, name, rpath, runpath); !ok {
+			m.Unresolved = append(m.Unresolved, lib)
+		}
+	}
+	return m
+}
+
+// Owners of dependencies that no package provides
+const (
+	PackageUnmanaged = "unmanaged"
+	PackageMissing   = "missing"
+)
+
+// Dependency is a shared library loaded by a binary
+type Dependency struct {
+	Name string
+	Path string `json:",omitempty"`
+	// Package is the owning package, "unmanaged" or "missing"
+	Package string
+	// NeededBy is the library requiring this one, empty when the binary itself does
+	NeededBy string `json:",omitempty"`
+}
+
+type library struct {
+	needed, rpath, runpath []string
+}
+
+// Dependencies resolves the needed libraries of the binary at name, and
+// recursively theirs, naming the package owning each of them
+func (r *Resolver) Dependencies(name string, info *elfinfo.Info, owner func(string) string) []Dependency {
+	type pending struct {
+		lib, neededBy  string
+		rpath, runpath []string
+	}
+	// the RPATH of an executable without RUNPATH applies to the libraries it loads
+	var exeRPath []string
+	if len(info.RunPath) == 0 {
+		for _, e := range info.RPath {
+			exeRPath = append(exeRPath, ExpandOrigin(e, name))
+		}
+	}
+	var queue []pending
+	for _, lib := range info.Needed {
+		queue = append(queue, pending{lib: lib, rpath: info.RPath, runpath: info.RunPath})
+	}
+	var deps []Dependency
+	loaded := make(map[string]bool)
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		if loaded[p.lib] {
+			continue
+		}
+		loaded[p.lib] = true
+
+		origin := name
+		if p.neededBy != "" {
+			origin = p.neededBy
+		}
+		d := Dependency{Name: p.lib, NeededBy: p.neededBy}
+		resolved, ok := r.Resolve(p.lib, origin, p.rpath, p.runpath)
+		if !ok {
+			d.Package = PackageMissing
+			deps = append(deps, d)
+			continue
+		}
+		d.Path = resolved
+		if d.Package = owner(resolved); d.Package == "" {
+			d.Package = PackageUnmanaged
+		}
+		deps = append(deps, d)
+
+		lib := r.library(resolved)
+		rpath := lib.rpath
+		if len(lib.runpath) == 0 {
+			rpath = append(append([]string{}, lib.rpath...), exeRPath...)
+		}
+		for _, n := range lib.needed {
+			queue = append(queue, pending{lib: n, neededBy: resolved, rpath: rpath, runpath: lib.runpath})
+		}
+	}
+	return deps
+}
+
+// library returns the dynamic section of the shared library at name, caching it
+func (r *Resolver) library(name string) library {
+	if r.libraries == nil {
+		r.libraries = make(map[string]library)
+	}
+	if lib, ok := r.libraries[name]; ok {
+		return lib
+	}
+	var lib library
+	if f, err := r.FS.Open(name); err == nil {
+		if info, err := elfinfo.Read(f); err == nil {
+			lib = library{needed: info.Needed, rpath: info.RPath, runpath: info.RunPath}
+			info.Close()
+		}
+	}
+	r.libraries[name] = lib
+	return lib
+}
@@ -0,0 +1,233 @@
+package ldso
+
+import (
+	"archive/tar"
+	"bytes"
+	"debug/elf"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aquasecurity/binfinder/pkg/elfinfo"
+	"github.com/aquasecurity/binfinder/pkg/elfinfo/elftest"
+	"github.com/aquasecurity/binfinder/pkg/rootfs"
+)
+
+type file struct {
+	name     string
+	mode     int64
+	content  string
+	linkname string
+	dir      bool
+}
+
+func loadFS(t *testing.T, files []file) *rootfs.FS {
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	for _, f := range files {
+		hdr := &tar.Header{Name: f.name, Mode: f.mode, Typeflag: tar.TypeReg, Size: int64(len(f.content))}
+		switch {
+		case f.dir:
+			hdr.Typeflag = tar.TypeDir
+		case f.linkname != "":
+			hdr.Typeflag = tar.TypeSymlink
+			hdr.Linkname = f.linkname
+		}
+		require.NoError(t, tw.WriteHeader(hdr))
+		_, err := tw.Write([]byte(f.content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	fs, err := rootfs.Load(buf, "")
+	require.NoError(t, err)
+	return fs
+}
+
+var testFiles = []file{
+	{name: "etc/", dir: true, mode: 0755},
+	{name: "etc/ld.so.conf", mode: 0644, content: "# libc default configuration\ninclude /etc/ld.so.conf.d/*.conf\n"},
+	{name: "etc/ld.so.conf.d/", dir: true, mode: 0755},
+	{name: "etc/ld.so.conf.d/libc.conf", mode: 0644, content: "/usr/local/lib\n"},
+	{name: "etc/ld.so.conf.d/x86_64-linux-gnu.conf", mode: 0644, content: "# Multiarch support\n/usr/local/lib/x86_64-linux-gnu\n/lib/x86_64-linux-gnu\n/usr/lib/x86_64-linux-gnu\n"},
+	{name: "etc/ld.so.conf.d/app.conf", mode: 0644, content: "/opt/app/lib /var/cache/app\n"},
+	{name: "etc/ld.so.conf.d/README", mode: 0644, content: "/not/a/conf\n"},
+	{name: "etc/ld.so.preload", mode: 0644, content: "/usr/lib/libhook.so # injected\n/lib/x86_64-linux-gnu/libc.so.6\n"},
+	{name: "lib", linkname: "usr/lib"},
+	{name: "usr/", dir: true, mode: 0755},
+	{name: "usr/lib/", dir: true, mode: 0755},
+	{name: "usr/lib/libhook.so", mode: 0755, content: "\x7fELF"},
+	{name: "usr/lib/x86_64-linux-gnu/", dir: true, mode: 0755},
+	{name: "usr/lib/x86_64-linux-gnu/libc.so.6", mode: 0755, content: "\x7fELF"},
+	{name: "usr/local/", dir: true, mode: 0755},
+	{name: "usr/local/lib/", dir: true, mode: 0755},
+	{name: "usr/local/bin/", dir: true, mode: 0755},
+	{name: "usr/local/bin/app", mode: 0755, content: "\x7fELF"},
+	{name: "usr/local/lib/app/", dir: true, mode: 0755},
+	{name: "opt/", dir: true, mode: 0755},
+	{name: "opt/app/", dir: true, mode: 0777},
+	{name: "opt/app/lib/", dir: true, mode: 0755},
+	{name: "tmp/", dir: true, mode: 01777},
+}
+
+var managed = map[string]bool{
+	"/etc/ld.so.conf":                         true,
+	"/etc/ld.so.conf.d/x86_64-linux-gnu.conf": true,
+	"/lib/x86_64-linux-gnu":                   true,
+	"/usr/lib/x86_64-linux-gnu":               true,
+	"/usr/lib/x86_64-linux-gnu/libc.so.6":     true,
+	"/usr/local/lib":                          true,
+}
+
+func TestParsePreload(t *testing.T) {
+	assert.Equal(t, []string{"/a.so", "/b.so", "/c.so"}, ParsePreload([]byte("/a.so /b.so:/c.so # comment\n#/d.so\n")))
+}
+
+func TestParseConf(t *testing.T) {
+	dirs, includes := ParseConf([]byte("include ld.so.conf.d/*.conf\nhwcap 0 nosegneg\n/usr/lib64 /opt/lib:/srv/lib # comment\n"))
+	assert.Equal(t, []string{"/usr/lib64", "/opt/lib", "/srv/lib"}, dirs)
+	assert.Equal(t, []string{"ld.so.conf.d/*.conf"}, includes)
+}
+
+func TestExpandOrigin(t *testing.T) {
+	assert.Equal(t, "/usr/local/bin/../lib", ExpandOrigin("$ORIGIN/../lib", "/usr/local/bin/app"))
+	assert.Equal(t, "/usr/local/bin/lib", ExpandOrigin("${ORIGIN}/lib", "/usr/local/bin/app"))
+	assert.Equal(t, "/opt/lib", ExpandOrigin("/opt/lib", "/usr/local/bin/app"))
+}
+
+func TestAuditor(t *testing.T) {
+	fs := loadFS(t, testFiles)
+	defer fs.Close()
+	a := Auditor{FS: fs, Managed: func(name string) bool { return managed[name] }}
+
+	dirs, sources := a.ConfDirs()
+	assert.Equal(t, []string{"/opt/app/lib", "/var/cache/app", "/usr/local/lib", "/usr/local/lib/x86_64-linux-gnu",
+		"/lib/x86_64-linux-gnu", "/usr/lib/x86_64-linux-gnu"}, dirs)
+	assert.Equal(t, "/etc/ld.so.conf.d/app.conf", sources[0])
+
+	assert.Equal(t, []Finding{
+		{Source: "/etc/ld.so.conf.d/app.conf", Kind: KindConf, Entry: "/opt/app/lib", Reason: ReasonWorldWritable, Path: "/opt/app"},
+		{Source: "/etc/ld.so.conf.d/app.conf", Kind: KindConf, Entry: "/var/cache/app", Reason: ReasonMissing},
+	}, a.Conf())
+
+	assert.Equal(t, []Finding{
+		{Source: PreloadFile, Kind: KindPreload, Entry: "/usr/lib/libhook.so", Reason: ReasonUnmanaged},
+	}, a.Preload())
+
+	assert.Equal(t, []Finding{
+		{Source: "/usr/local/bin/app", Kind: KindRPath, Entry: "/tmp/lib", Reason: ReasonWorldWritable, Path: "/tmp"},
+		{Source: "/usr/local/bin/app", Kind: KindRunPath, Entry: "$ORIGIN/../lib/app", Reason: ReasonUnmanaged},
+		{Source: "/usr/local/bin/app", Kind: KindRunPath, Entry: "lib", Reason: ReasonRelative},
+	}, a.Binary("/usr/local/bin/app", []string{"/tmp/lib", "/usr/local/lib"}, []string{"$ORIGIN/../lib/app", "lib"}))
+}
+
+func TestLibc(t *testing.T) {
+	assert.Equal(t, LibcMusl, Libc("/lib/ld-musl-x86_64.so.1", nil))
+	assert.Equal(t, LibcGlibc, Libc("/lib64/ld-linux-x86-64.so.2", nil))
+	assert.Equal(t, LibcGlibc, Libc("/lib/ld64.so.2", nil))
+	assert.Equal(t, LibcMusl, Libc("", []string{"libc.musl-x86_64.so.1"}))
+	assert.Equal(t, LibcGlibc, Libc("/opt/custom/loader", []string{"libpthread.so.0", "libc.so.6"}))
+	assert.Equal(t, "", Libc("", nil))
+}
+
+func TestResolver(t *testing.T) {
+	files := append([]file{
+		{name: "usr/lib/ld-musl-x86_64.so.1", mode: 0755, content: "\x7fELF"},
+		{name: "etc/ld-musl-x86_64.path", mode: 0644, content: "/lib:/usr/lib\n/opt/musl/lib\n"},
+		{name: "usr/lib/libssl.so.1.1", mode: 0755, content: "\x7fELF"},
+		{name: "usr/local/lib/app/libapp.so", mode: 0755, content: "\x7fELF"},
+		{name: "usr/lib/libcrypto.so.1.1", dir: true, mode: 0755},
+	}, testFiles...)
+	fs := loadFS(t, files)
+	defer fs.Close()
+
+	glibc := NewResolver(fs, LibcGlibc)
+	assert.Equal(t, []string{"/opt/app/lib", "/var/cache/app", "/usr/local/lib", "/usr/local/lib/x86_64-linux-gnu",
+		"/lib/x86_64-linux-gnu", "/usr/lib/x86_64-linux-gnu", "/lib64", "/usr/lib64", "/lib", "/usr/lib"}, glibc.Dirs)
+	musl := NewResolver(fs, LibcMusl)
+	assert.Equal(t, []string{"/lib", "/usr/lib", "/opt/musl/lib"}, musl.Dirs)
+
+	testCases := []struct {
+		name    string
+		lib     string
+		rpath   []string
+		runpath []string
+		want    string
+		wantOK  bool
+	}{
+		{name: "system directory", lib: "libc.so.6", want: "/lib/x86_64-linux-gnu/libc.so.6", wantOK: true},
+		{name: "runpath with origin", lib: "libapp.so", runpath: []string{"$ORIGIN/../lib/app"}, want: "/usr/local/lib/app/libapp.so", wantOK: true},
+		{name: "rpath ignored with runpath", lib: "libapp.so", rpath: []string{"/usr/local/lib/app"}, runpath: []string{"/opt"}},
+		{name: "rpath", lib: "libapp.so", rpath: []string{"/usr/local/lib/app"}, want: "/usr/local/lib/app/libapp.so", wantOK: true},
+		{name: "directory is not a library", lib: "libcrypto.so.1.1"},
+		{name: "missing", lib: "libz.so.1"},
+		{name: "path", lib: "/usr/lib/libssl.so.1.1", want: "/usr/lib/libssl.so.1.1", wantOK: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := glibc.Resolve(tc.lib, "/usr/local/bin/app", tc.rpath, tc.runpath)
+			assert.Equal(t, tc.wantOK, ok)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+
+	assert.Nil(t, glibc.CheckLibc("/usr/local/bin/app", LibcGlibc, "/lib/x86_64-linux-gnu/libc.so.6", []string{"libc.so.6"}, nil, nil))
+	assert.Nil(t, musl.CheckLibc("/usr/local/bin/static", LibcMusl, "", nil, nil, nil))
+	assert.Equal(t, &LibcMismatch{
+		Path:        "/usr/local/bin/app",
+		Interpreter: "/lib64/ld-linux-x86-64.so.2",
+		Libc:        LibcGlibc,
+		ImageLibc:   LibcMusl,
+		Reason:      ReasonMissingInterpreter,
+		Unresolved:  []string{"libc.so.6", "libz.so.1"},
+	}, musl.CheckLibc("/usr/local/bin/app", LibcMusl, "/lib64/ld-linux-x86-64.so.2", []string{"libssl.so.1.1", "libc.so.6", "libz.so.1"}, nil, nil))
+	assert.Equal(t, &LibcMismatch{
+		Path:        "/usr/local/bin/app",
+		Interpreter: "/lib/ld-musl-x86_64.so.1",
+		Libc:        LibcMusl,
+		ImageLibc:   LibcGlibc,
+		Reason:      ReasonIncompatible,
+	}, glibc.CheckLibc("/usr/local/bin/app", LibcGlibc, "/lib/ld-musl-x86_64.so.1", []string{"libssl.so.1.1"}, nil, nil))
+}
+
+func TestResolver_Dependencies(t *testing.T) {
+	libssl := elftest.File{Type: elf.ET_DYN, SOName: "libssl.so.1.1", Needed: []string{"libcrypto.so.1.1", "libc.so.6"}}
+	libcrypto := elftest.File{Type: elf.ET_DYN, SOName: "libcrypto.so.1.1", Needed: []string{"libc.so.6", "libz.so.1"}}
+	libapp := elftest.File{Type: elf.ET_DYN, SOName: "libapp.so", Needed: []string{"libhelper.so"}}
+	libhelper := elftest.File{Type: elf.ET_DYN, SOName: "libhelper.so"}
+	fs := loadFS(t, append([]file{
+		{name: "usr/lib/x86_64-linux-gnu/libssl.so.1.1", mode: 0644, content: string(libssl.Bytes())},
+		{name: "usr/lib/x86_64-linux-gnu/libcrypto.so.1.1", mode: 0644, content: string(libcrypto.Bytes())},
+		{name: "opt/app/lib/libapp.so", mode: 0644, content: string(libapp.Bytes())},
+		{name: "usr/local/bin/lib/libhelper.so", mode: 0644, content: string(libhelper.Bytes())},
+	}, testFiles...))
+	defer fs.Close()
+
+	owners := map[string]string{
+		"/lib/x86_64-linux-gnu/libc.so.6":        "libc6",
+		"/lib/x86_64-linux-gnu/libssl.so.1.1":    "libssl1.1",
+		"/lib/x86_64-linux-gnu/libcrypto.so.1.1": "libssl1.1",
+	}
+	r := NewResolver(fs, LibcGlibc)
+	binary := elftest.File{
+		Type:        elf.ET_DYN,
+		Interpreter: "/lib64/ld-linux-x86-64.so.2",
+		Needed:      []string{"libssl.so.1.1", "libapp.so", "libc.so.6"},
+		RPath:       "$ORIGIN/lib",
+	}
+	info, err := elfinfo.Read(bytes.NewReader(binary.Bytes()))
+	require.NoError(t, err)
+	defer info.Close()
+
+	got := r.Dependencies("/usr/local/bin/app", info, func(name string) string { return owners[name] })
+	assert.Equal(t, []Dependency{
+		{Name: "libssl.so.1.1", Path: "/lib/x86_64-linux-gnu/libssl.so.1.1", Package: "libssl1.1"},
+		{Name: "libapp.so", Path: "/opt/app/lib/libapp.so", Package: PackageUnmanaged},
+		{Name: "libc.so.6", Path: "/lib/x86_64-linux-gnu/libc.so.6", Package: "libc6"},
+		{Name: "libcrypto.so.1.1", Path: "/lib/x86_64-linux-gnu/libcrypto.so.1.1", Package: "libssl1.1",
+			NeededBy: "/lib/x86_64-linux-gnu/libssl.so.1.1"},
+		{Name: "libhelper.so", Path: "/usr/local/bin/lib/libhelper.so", Package: PackageUnmanaged,
+			NeededBy: "/opt/app/lib/libapp.so"},
+		{Name: "libz.so.1", Package: PackageMissing, NeededBy: "/lib/x86_64-linux-gnu/libcrypto.so.1.1"},
+	}, got)
+}
@@ -0,0 +1,158 @@
+package pkgdb
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Files of a reference database directory, one of them is expected
+const (
+	// APKInstalled is a copy of /lib/apk/db/installed
+	APKInstalled = "installed"
+	// DpkgInfo is a copy of /var/lib/dpkg/info, only its .list files are read
+	DpkgInfo = "info"
+	// FilesTSV holds a "<package>\t<file>" line per packaged file, as printed by
+	// rpm -qa --qf '[%{NAME}\t%{FILENAMES}\n]'
+	FilesTSV = "files.tsv"
+)
+
+// Release returns the "<ID>-<VERSION_ID>" name of the distribution release
+// described by the content of /etc/os-release, e.g. debian-11
+func Release(osRelease []byte) string {
+	var id, version string
+	s := bufio.NewScanner(bytes.NewReader(osRelease))
+	for s.Scan() {
+		parts := strings.SplitN(strings.TrimSpace(s.Text()), "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		value := strings.Trim(parts[1], `"'`)
+		switch parts[0] {
+		case "ID":
+			id = value
+		case "VERSION_ID":
+			version = value
+		}
+	}
+	if id == "" {
+		return ""
+	}
+	if version == "" {
+		return id
+	}
+	return id + "-" + version
+}
+
+// Find returns the directory of root holding the reference database of the
+// release, falling back to less precise versions of the same major release:
+// alpine-3.14.2, then alpine-3.14, then alpine-3. The version is never
+// dropped, another release of the distribution has other packages.
+func Find(root, release string) (string, bool) {
+	// the version starts after the last dash, IDs may hold dashes too, e.g. opensuse-leap-15.3
+	major := strings.LastIndex(release, "-") + 1
+	if release == "" {
+		return "", false
+	}
+	for {
+		dir := filepath.Join(root, release)
+		if fi, err := os.Stat(dir); err == nil && fi.IsDir() {
+			return dir, true
+		}
+		i := strings.LastIndex(release, ".")
+		if i < major {
+			return "", false
+		}
+		release = release[:i]
+	}
+}
+
+// Load returns the package of every file listed by the reference database in dir
+func Load(dir string) (map[string]string, error) {
+	if b, err := ioutil.ReadFile(filepath.Join(dir, APKInstalled)); err == nil {
+		return ParseAPKInstalled(b), nil
+	}
+	if b, err := ioutil.ReadFile(filepath.Join(dir, FilesTSV)); err == nil {
+		return ParseTSV(b), nil
+	}
+	lists, err := filepath.Glob(filepath.Join(dir, DpkgInfo, "*.list"))
+	if err != nil {
+		return nil, err
+	}
+	if len(lists) == 0 {
+		return nil, fmt.Errorf("%v: no %v, %v or %v/*.list reference database", dir, APKInstalled, FilesTSV, DpkgInfo)
+	}
+	files := make(map[string]string)
+	for _, name := range lists {
+		b, err := ioutil.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		for f := range ParseDpkgList(b) {
+			files[f] = DpkgPackage(filepath.Base(name))
+		}
+	}
+	return files, nil
+}
+
+// ParseAPKInstalled returns the package of every file of an apk installed database
+func ParseAPKInstalled(data []byte) map[string]string {
+	files := make(map[string]string)
+	var pkg, dir string
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for s.Scan() {
+		line := s.Text()
+		if len(line) < 2 || line[1] != ':' {
+			continue
+		}
+		switch line[0] {
+		case 'P':
+			pkg, dir = line[2:], ""
+		case 'F':
+			dir = line[2:]
+		case 'R':
+			files[path.Join("/", dir, line[2:])] = pkg
+		}
+	}
+	return files
+}
+
+// ParseDpkgList returns the files of a dpkg .list file
+func ParseDpkgList(data []byte) map[string]bool {
+	files := make(map[string]bool)
+	for _, f := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(f) != "" {
+			files[f] = true
+		}
+	}
+	return files
+}
+
+// DpkgPackage returns the package of a dpkg list file, dpkg names them <package>[:<arch>].list
+func DpkgPackage(listName string) string {
+	return strings.Split(strings.TrimSuffix(listName, ".list"), ":")[0]
+}
+
+// ParseTSV returns the package of every "<package>\t<file>" line
+func ParseTSV(data []byte) map[string]string {
+	files := make(map[string]string)
+	for _, line := range strings.Split(string(data), "\n") {
+		parts := strings.SplitN(line, "\t", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		f := strings.TrimSpace(parts[1])
+		if f != "" && f != "(none)" {
+			if !strings.HasPrefix(f, "/") {
+				f = "/" + f
+			}
+			files[f] = strings.TrimSpace(parts[0])
+		}
+	}
+	return files
+}
@@ -0,0 +1,54 @@
+package pkgdb
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelease(t *testing.T) {
+	assert.Equal(t, "debian-11", Release([]byte("PRETTY_NAME=\"Debian GNU/Linux 11 (bullseye)\"\nVERSION_ID=\"11\"\nID=debian\n")))
+	assert.Equal(t, "alpine-3.14.2", Release([]byte("NAME=\"Alpine Linux\"\nID=alpine\nVERSION_ID=3.14.2\n")))
+	assert.Equal(t, "arch", Release([]byte("ID=arch\n")))
+	assert.Equal(t, "", Release([]byte("NAME=unknown\n")))
+}
+
+func TestFind(t *testing.T) {
+	dir, ok := Find("testdata", "alpine-3.14.2")
+	assert.True(t, ok)
+	assert.Equal(t, filepath.Join("testdata", "alpine-3.14"), dir)
+	_, ok = Find("testdata", "alpine-3.13.5")
+	assert.False(t, ok)
+	dir, ok = Find("testdata", "debian-11.2")
+	assert.True(t, ok)
+	assert.Equal(t, filepath.Join("testdata", "debian-11"), dir)
+	_, ok = Find("testdata", "debian-12")
+	assert.False(t, ok, "another release of the distribution")
+	_, ok = Find("testdata", "alpine")
+	assert.False(t, ok)
+	_, ok = Find("testdata", "")
+	assert.False(t, ok)
+}
+
+func TestLoad(t *testing.T) {
+	testCases := []struct {
+		release string
+		want    map[string]string
+	}{
+		{release: "alpine-3.14", want: map[string]string{"/bin/busybox": "busybox", "/etc/securetty": "busybox", "/sbin/apk": "apk-tools"}},
+		{release: "debian-11", want: map[string]string{"/.": "libc6", "/usr": "curl", "/usr/bin": "curl", "/usr/bin/curl": "curl",
+			"/usr/lib/x86_64-linux-gnu/libc.so.6": "libc6"}},
+		{release: "centos-8", want: map[string]string{"/usr/bin/bash": "bash", "/usr/bin/ls": "coreutils"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.release, func(t *testing.T) {
+			got, err := Load(filepath.Join("testdata", tc.release))
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+	_, err := Load("testdata")
+	assert.Error(t, err)
+}
@@ -0,0 +1,180 @@
+package pkgindex
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Matching methods of a binary against packaged files
+const (
+	BySHA256  = "sha256"
+	ByBuildID = "build-id"
+)
+
+// File is a file installed by a package
+type File struct {
+	Path    string
+	Package string
+	SHA256  string
+	BuildID string `json:",omitempty"`
+}
+
+// Image holds the packaged files of a scanned image
+type Image struct {
+	Image string
+	Files []File
+}
+
+// Relocation is a packaged file an unmanaged binary is a copy of
+type Relocation struct {
+	Package string
+	Path    string
+	// Image is the image the package is installed in, empty for the scanned image
+	Image string `json:",omitempty"`
+	By    string
+	Label string
+}
+
+func relocation(f File, image, by string) *Relocation {
+	r := &Relocation{Package: f.Package, Path: f.Path, Image: image, By: by}
+	if image == "" {
+		r.Label = fmt.Sprintf("relocated from package %v (%v)", f.Package, f.Path)
+	} else {
+		r.Label = fmt.Sprintf("relocated from package %v (image %v)", f.Package, image)
+	}
+	return r
+}
+
+type ref struct {
+	image string
+	file  File
+}
+
+// Index holds the packaged files of the scanned images, one JSON file per image
+type Index struct {
+	dir string
+
+	mu        sync.RWMutex
+	bySHA256  map[string][]ref
+	byBuildID map[string][]ref
+}
+
+// Open loads the index stored in dir, creating the directory if needed
+func Open(dir string) (*Index, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	ix := &Index{dir: dir, bySHA256: make(map[string][]ref), byBuildID: make(map[string][]ref)}
+	names, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		b, err := ioutil.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		var img Image
+		if err = json.Unmarshal(b, &img); err != nil {
+			return nil, fmt.Errorf("%v: invalid package index: %v", name, err)
+		}
+		ix.add(img)
+	}
+	return ix, nil
+}
+
+// FileName returns the name of the index file of the image
+func FileName(image string) string {
+	return strings.ReplaceAll(image, "/", "-") + ".json"
+}
+
+// Add stores the packaged files of an image, replacing those of a previous scan
+func (ix *Index) Add(img Image) error {
+	b, err := json.MarshalIndent(img, "", " ")
+	if err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(filepath.Join(ix.dir, FileName(img.Image)), b, 0644); err != nil {
+		return err
+	}
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.remove(img.Image)
+	ix.add(img)
+	return nil
+}
+
+func (ix *Index) add(img Image) {
+	for _, f := range img.Files {
+		r := ref{image: img.Image, file: f}
+		ix.bySHA256[f.SHA256] = append(ix.bySHA256[f.SHA256], r)
+		if f.BuildID != "" {
+			ix.byBuildID[f.BuildID] = append(ix.byBuildID[f.BuildID], r)
+		}
+	}
+}
+
+func (ix *Index) remove(image string) {
+	for _, m := range []map[string][]ref{ix.bySHA256, ix.byBuildID} {
+		for k, refs := range m {
+			kept := refs[:0]
+			for _, r := range refs {
+				if r.image != image {
+					kept = append(kept, r)
+				}
+			}
+			if len(kept) == 0 {
+				delete(m, k)
+			} else {
+				m[k] = kept
+			}
+		}
+	}
+}
+
+// Lookup returns the packaged file of another image than exclude that the
+// binary with the given hash and build ID is a copy of
+func (ix *Index) Lookup(sha, buildID, exclude string) *Relocation {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	for _, r := range ix.bySHA256[sha] {
+		if r.image != exclude {
+			return relocation(r.file, r.image, BySHA256)
+		}
+	}
+	if buildID == "" {
+		return nil
+	}
+	for _, r := range ix.byBuildID[buildID] {
+		if r.image != exclude {
+			return relocation(r.file, r.image, ByBuildID)
+		}
+	}
+	return nil
+}
+
+// Match returns the packaged file of the scanned image that the binary with
+// the given hash and build ID is a copy of
+func Match(files []File, sha, buildID string) *Relocation {
+	for _, f := range files {
+		if f.SHA256 == sha {
+			return relocation(f, "", BySHA256)
+		}
+	}
+	if buildID == "" {
+		return nil
+	}
+	for _, f := range files {
+		if f.BuildID == buildID {
+			return relocation(f, "", ByBuildID)
+		}
+	}
+	return nil
+}
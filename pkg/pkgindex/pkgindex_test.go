@@ -0,0 +1,53 @@
+package pkgindex
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var python = Image{
+	Image: "python:3.9",
+	Files: []File{
+		{Path: "/usr/bin/python3.9", Package: "python3.9-minimal", SHA256: "aaaa", BuildID: "b1"},
+		{Path: "/usr/bin/ls", Package: "coreutils", SHA256: "cccc", BuildID: "b2"},
+	},
+}
+
+func TestIndex(t *testing.T) {
+	dir, err := ioutil.TempDir("", "TestIndex-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	ix, err := Open(dir)
+	require.NoError(t, err)
+	require.NoError(t, ix.Add(python))
+	assert.FileExists(t, filepath.Join(dir, "python:3.9.json"))
+
+	// reopened from disk
+	ix, err = Open(dir)
+	require.NoError(t, err)
+	assert.Equal(t, &Relocation{Package: "python3.9-minimal", Path: "/usr/bin/python3.9", Image: "python:3.9",
+		By: BySHA256, Label: "relocated from package python3.9-minimal (image python:3.9)"},
+		ix.Lookup("aaaa", "", "app:latest"))
+	assert.Equal(t, &Relocation{Package: "coreutils", Path: "/usr/bin/ls", Image: "python:3.9", By: ByBuildID,
+		Label: "relocated from package coreutils (image python:3.9)"}, ix.Lookup("ffff", "b2", "app:latest"))
+	assert.Nil(t, ix.Lookup("aaaa", "b1", "python:3.9"))
+	assert.Nil(t, ix.Lookup("ffff", "", "app:latest"))
+
+	// a new scan replaces the files of the image
+	require.NoError(t, ix.Add(Image{Image: "python:3.9", Files: python.Files[1:]}))
+	assert.Nil(t, ix.Lookup("aaaa", "", "app:latest"))
+	assert.NotNil(t, ix.Lookup("cccc", "", "app:latest"))
+}
+
+func TestMatch(t *testing.T) {
+	assert.Equal(t, &Relocation{Package: "python3.9-minimal", Path: "/usr/bin/python3.9", By: BySHA256,
+		Label: "relocated from package python3.9-minimal (/usr/bin/python3.9)"}, Match(python.Files, "aaaa", ""))
+	assert.Equal(t, ByBuildID, Match(python.Files, "ffff", "b2").By)
+	assert.Nil(t, Match(python.Files, "ffff", ""))
+}
@@ -0,0 +1,120 @@
+package privilege
+
+import (
+	"archive/tar"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// SeverityHigh is the severity of every privileged unmanaged binary
+const SeverityHigh = "high"
+
+const capabilityRecord = "SCHILY.xattr.security.capability"
+
+// vfs_cap_data revisions, see linux/capability.h
+const (
+	capRevisionMask = 0xFF000000
+	capRevision1    = 0x01000000
+	capRevision2    = 0x02000000
+	capRevision3    = 0x03000000
+	capEffective    = 0x000001
+)
+
+// capabilityNames are indexed by capability number
+var capabilityNames = []string{
+	"cap_chown", "cap_dac_override", "cap_dac_read_search", "cap_fowner", "cap_fsetid", "cap_kill",
+	"cap_setgid", "cap_setuid", "cap_setpcap", "cap_linux_immutable", "cap_net_bind_service",
+	"cap_net_broadcast", "cap_net_admin", "cap_net_raw", "cap_ipc_lock", "cap_ipc_owner", "cap_sys_module",
+	"cap_sys_rawio", "cap_sys_chroot", "cap_sys_ptrace", "cap_sys_pacct", "cap_sys_admin", "cap_sys_boot",
+	"cap_sys_nice", "cap_sys_resource", "cap_sys_time", "cap_sys_tty_config", "cap_mknod", "cap_lease",
+	"cap_audit_write", "cap_audit_control", "cap_setfcap", "cap_mac_override", "cap_mac_admin",
+	"cap_syslog", "cap_wake_alarm", "cap_block_suspend", "cap_audit_read", "cap_perfmon", "cap_bpf",
+	"cap_checkpoint_restore",
+}
+
+// Privilege describes a file that runs with more privileges than its caller
+type Privilege struct {
+	Path         string
+	Mode         string
+	UID          int
+	GID          int
+	Setuid       bool
+	Setgid       bool
+	Capabilities []string `json:",omitempty"`
+	Severity     string
+}
+
+// Inspect returns the privileges granted by the file described by hdr,
+// or nil when it is not privileged
+func Inspect(name string, hdr *tar.Header) (*Privilege, error) {
+	p := &Privilege{
+		Path:     name,
+		Mode:     fmt.Sprintf("%04o", hdr.Mode&07777),
+		UID:      hdr.Uid,
+		GID:      hdr.Gid,
+		Setuid:   hdr.Mode&04000 != 0,
+		Setgid:   hdr.Mode&02000 != 0,
+		Severity: SeverityHigh,
+	}
+	var err error
+	if xattr, ok := hdr.PAXRecords[capabilityRecord]; ok {
+		p.Capabilities, err = ParseCapabilities([]byte(xattr))
+	}
+	if !p.Setuid && !p.Setgid && len(p.Capabilities) == 0 {
+		return nil, err
+	}
+	return p, err
+}
+
+// ParseCapabilities decodes a security.capability extended attribute into
+// getcap style capability names, e.g. cap_net_raw+ep
+func ParseCapabilities(xattr []byte) ([]string, error) {
+	if len(xattr) < 4 {
+		return nil, errors.New("invalid security.capability attribute")
+	}
+	magic := binary.LittleEndian.Uint32(xattr)
+	words := 0
+	switch magic & capRevisionMask {
+	case capRevision1:
+		words = 1
+	case capRevision2, capRevision3:
+		words = 2
+	default:
+		return nil, fmt.Errorf("unknown security.capability revision: %#x", magic&capRevisionMask)
+	}
+	if len(xattr) < 4+words*8 {
+		return nil, errors.New("truncated security.capability attribute")
+	}
+	var permitted, inheritable uint64
+	for i := 0; i < words; i++ {
+		permitted |= uint64(binary.LittleEndian.Uint32(xattr[4+i*8:])) << (32 * uint(i))
+		inheritable |= uint64(binary.LittleEndian.Uint32(xattr[8+i*8:])) << (32 * uint(i))
+	}
+	var caps []string
+	for i := uint(0); i < 64; i++ {
+		bit := uint64(1) << i
+		if permitted&bit == 0 && inheritable&bit == 0 {
+			continue
+		}
+		flags := "+"
+		if magic&capEffective != 0 {
+			flags += "e"
+		}
+		if inheritable&bit != 0 {
+			flags += "i"
+		}
+		if permitted&bit != 0 {
+			flags += "p"
+		}
+		caps = append(caps, capabilityName(i)+flags)
+	}
+	return caps, nil
+}
+
+func capabilityName(i uint) string {
+	if int(i) < len(capabilityNames) {
+		return capabilityNames[i]
+	}
+	return fmt.Sprintf("cap_%d", i)
+}
@@ -0,0 +1,78 @@
+package privilege
+
+import (
+	"archive/tar"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCapabilities(t *testing.T) {
+	testCases := []struct {
+		name    string
+		xattr   []byte
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "v2 effective net_bind_service and net_raw",
+			// magic 0x02000001, permitted lo 0x2400, inheritable lo 0, permitted hi 0, inheritable hi 0
+			xattr: []byte{0x01, 0x00, 0x00, 0x02, 0x00, 0x24, 0x00, 0x00, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+			want:  []string{"cap_net_bind_service+ep", "cap_net_raw+ep"},
+		},
+		{
+			name: "v3 permitted and inheritable bpf",
+			// cap_bpf is 39, bit 7 of the high word
+			xattr: []byte{0x00, 0x00, 0x00, 0x03, 0, 0, 0, 0, 0, 0, 0, 0, 0x80, 0, 0, 0, 0x80, 0, 0, 0, 0, 0, 0, 0},
+			want:  []string{"cap_bpf+ip"},
+		},
+		{
+			name:  "v1 sys_admin",
+			xattr: []byte{0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x20, 0x00, 0, 0, 0, 0},
+			want:  []string{"cap_sys_admin+p"},
+		},
+		{
+			name:    "unknown revision",
+			xattr:   []byte{0x00, 0x00, 0x00, 0x07, 0, 0, 0, 0, 0, 0, 0, 0},
+			wantErr: "unknown security.capability revision: 0x7000000",
+		},
+		{
+			name:    "truncated",
+			xattr:   []byte{0x00, 0x00, 0x00, 0x02, 0, 0, 0, 0},
+			wantErr: "truncated security.capability attribute",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseCapabilities(tc.xattr)
+			if tc.wantErr != "" {
+				assert.EqualError(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestInspect(t *testing.T) {
+	got, err := Inspect("/usr/local/bin/sudo", &tar.Header{Mode: 04755, Uid: 0, Gid: 0})
+	require.NoError(t, err)
+	assert.Equal(t, &Privilege{Path: "/usr/local/bin/sudo", Mode: "4755", Setuid: true, Severity: SeverityHigh}, got)
+
+	got, err = Inspect("/usr/local/bin/ping", &tar.Header{Mode: 0755, Uid: 0, Gid: 0, PAXRecords: map[string]string{
+		capabilityRecord: string([]byte{0x01, 0x00, 0x00, 0x02, 0x00, 0x20, 0x00, 0x00, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}),
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, &Privilege{Path: "/usr/local/bin/ping", Mode: "0755", Capabilities: []string{"cap_net_raw+ep"},
+		Severity: SeverityHigh}, got)
+
+	got, err = Inspect("/usr/local/bin/crontab", &tar.Header{Mode: 02755, Uid: 0, Gid: 101})
+	require.NoError(t, err)
+	assert.Equal(t, &Privilege{Path: "/usr/local/bin/crontab", Mode: "2755", GID: 101, Setgid: true, Severity: SeverityHigh}, got)
+
+	got, err = Inspect("/usr/local/bin/app", &tar.Header{Mode: 0755})
+	require.NoError(t, err)
+	assert.Nil(t, got)
+}
@@ -0,0 +1,225 @@
+package provenance
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/aquasecurity/binfinder/pkg/rootfs"
+)
+
+// Installation methods
+const (
+	CopiedFromStage   = "copied-from-stage"
+	Downloaded        = "downloaded"
+	BuiltFromSource   = "built-from-source"
+	LanguageToolchain = "language-toolchain"
+	Unknown           = "unknown"
+)
+
+// Provenance is how a binary was most likely installed, and why
+type Provenance struct {
+	Method   string
+	Evidence []string `json:",omitempty"`
+}
+
+// maxEvidence is the longest history step quoted as evidence
+const maxEvidence = 200
+
+// minNameLength is the shortest binary name matched on its own in history steps and source names
+const minNameLength = 3
+
+// toolchainDirs are the directories language package managers install into
+var toolchainDirs = []string{"/node_modules/", "/site-packages/", "/dist-packages/", "/gems/", "/vendor/bundle/",
+	"/go/bin/", "/.cargo/bin/", "/cargo/bin/"}
+
+var (
+	sourceBuild   = regexp.MustCompile(`\bmake\b[^&;|]*\binstall\b|\./configure\b|\bcmake\b|\bninja\b[^&;|]*\binstall\b|\bgo build\b|\bcargo build\b|\bgcc\b|\bcc -o\b`)
+	toolchainStep = regexp.MustCompile(`\bgo (install|get)\b|\bcargo install\b|\bnpm (install|i|ci)\b|\byarn (global )?add\b|\bpip3? install\b|\bgem install\b|\bbundle install\b`)
+	download      = regexp.MustCompile(`\b(curl|wget)\b|\bADD\s+(--\S+\s+)*https?://`)
+	copyFromStage = regexp.MustCompile(`\bCOPY\s+(--\S+\s+)*--from=`)
+	copyOrAdd     = regexp.MustCompile(`^(COPY|ADD)\b`)
+	// metadataStep are the instructions that add no files, e.g. CMD naming the binary
+	metadataStep = regexp.MustCompile(`^(CMD|ENTRYPOINT|ENV|LABEL|EXPOSE|WORKDIR|USER|VOLUME|STOPSIGNAL|HEALTHCHECK|SHELL|ARG|ONBUILD|MAINTAINER)\b`)
+
+	sourceFiles      = map[string]bool{"configure": true, "CMakeLists.txt": true, "Makefile": true, "meson.build": true, "Cargo.toml": true, "go.mod": true}
+	sourceArchives   = []string{".tar.gz", ".tgz", ".tar.xz", ".txz", ".tar.bz2", ".tbz2", ".zip"}
+	sourceRoots      = []string{"/usr/src/", "/usr/local/src/", "/tmp/", "/build/", "/src/", "/root/", "/opt/", "/home/"}
+	installManifests = regexp.MustCompile(`^install_manifest.*\.txt$`)
+)
+
+// Classifier classifies the binaries of an image from its history and its filesystem
+type Classifier struct {
+	// history are the created_by commands of the image layers, newest first
+	history []string
+	// manifests maps the files listed by a CMake install manifest to the manifest
+	manifests map[string]string
+	// sources are the source archives and trees left in the image
+	sources []string
+}
+
+// NewClassifier indexes the history, newest step first as docker history lists
+// them, and the build traces found in the image filesystem outside of the
+// files skip reports as out of the scan scope when it is set
+func NewClassifier(history []string, fs *rootfs.FS, skip func(name string) bool) *Classifier {
+	c := &Classifier{manifests: make(map[string]string)}
+	for _, h := range history {
+		c.history = append(c.history, Step(h))
+	}
+	seen := make(map[string]bool)
+	fs.Walk(func(name string, e *rootfs.Entry) {
+		if e.Header.Typeflag != tar.TypeReg || (skip != nil && skip(name)) {
+			return
+		}
+		base := path.Base(name)
+		switch {
+		case installManifests.MatchString(base):
+			if content, err := fs.ReadFile(name); err == nil {
+				s := bufio.NewScanner(bytes.NewReader(content))
+				for s.Scan() {
+					if f := strings.TrimSpace(s.Text()); f != "" {
+						c.manifests[path.Clean(f)] = name
+					}
+				}
+			}
+		case sourceFiles[base] && underSourceRoot(name):
+			if dir := path.Dir(name); !seen[dir] {
+				seen[dir] = true
+				c.sources = append(c.sources, dir)
+			}
+		case isArchive(base):
+			c.sources = append(c.sources, name)
+		}
+	})
+	return c
+}
+
+// Step returns the instruction of a history created_by string, without the
+// shell of the classic builder and the buildkit marker
+func Step(createdBy string) string {
+	s := strings.TrimSpace(createdBy)
+	s = strings.TrimPrefix(s, "/bin/sh -c #(nop) ")
+	s = strings.TrimPrefix(s, "/bin/sh -c ")
+	s = strings.TrimSuffix(s, " # buildkit")
+	return strings.TrimSpace(s)
+}
+
+// Classify returns the most likely installation method of the binary
+func (c *Classifier) Classify(binary string) Provenance {
+	for _, d := range toolchainDirs {
+		if strings.Contains(binary, d) {
+			return Provenance{Method: LanguageToolchain, Evidence: []string{fmt.Sprintf("installed under %v", strings.Trim(d, "/"))}}
+		}
+	}
+	if step, ok := c.mentioning(binary); ok {
+		return Provenance{Method: stepMethod(step), Evidence: []string{quote(step)}}
+	}
+	if m, ok := c.manifests[binary]; ok {
+		return Provenance{Method: BuiltFromSource, Evidence: []string{fmt.Sprintf("listed in %v", m)}}
+	}
+	if p := c.source(binary); p.Method != "" {
+		return p
+	}
+	if step, ok := c.copiedInto(path.Dir(binary)); ok {
+		return Provenance{Method: stepMethod(step), Evidence: []string{quote(step)}}
+	}
+	return Provenance{Method: Unknown}
+}
+
+// mentioning returns the newest history step naming the binary by path, or
+// by name when it is not too short to be a common word
+func (c *Classifier) mentioning(binary string) (string, bool) {
+	base := path.Base(binary)
+	name := regexp.MustCompile(`(^|[^\w.-])` + regexp.QuoteMeta(base) + `($|[^\w.-])`)
+	for _, step := range c.history {
+		if metadataStep.MatchString(step) {
+			continue
+		}
+		if strings.Contains(step, binary) || (len(base) >= minNameLength && name.MatchString(step)) {
+			return step, true
+		}
+	}
+	return "", false
+}
+
+// copiedInto returns the newest COPY or ADD step whose destination is dir
+func (c *Classifier) copiedInto(dir string) (string, bool) {
+	for _, step := range c.history {
+		if !copyOrAdd.MatchString(step) {
+			continue
+		}
+		fields := strings.Fields(step)
+		if path.Clean(fields[len(fields)-1]) == dir {
+			return step, true
+		}
+	}
+	return "", false
+}
+
+// source returns the built-from-source provenance of a binary named like a
+// source archive or tree left in the image
+func (c *Classifier) source(binary string) Provenance {
+	tokens := strings.FieldsFunc(path.Base(binary), func(r rune) bool {
+		return r == '-' || r == '_' || r == '.'
+	})
+	if len(tokens) == 0 || len(tokens[0]) < minNameLength {
+		return Provenance{}
+	}
+	token := strings.ToLower(tokens[0])
+	var evidence []string
+	for _, s := range c.sources {
+		if strings.HasPrefix(strings.ToLower(path.Base(s)), token) {
+			evidence = append(evidence, fmt.Sprintf("source %v present", s))
+		}
+	}
+	if len(evidence) == 0 {
+		return Provenance{}
+	}
+	return Provenance{Method: BuiltFromSource, Evidence: evidence}
+}
+
+// stepMethod returns the installation method of a history step
+func stepMethod(step string) string {
+	switch {
+	case copyFromStage.MatchString(step):
+		return CopiedFromStage
+	case copyOrAdd.MatchString(step) && !download.MatchString(step):
+		return Unknown
+	case sourceBuild.MatchString(step):
+		return BuiltFromSource
+	case toolchainStep.MatchString(step):
+		return LanguageToolchain
+	case download.MatchString(step):
+		return Downloaded
+	}
+	return Unknown
+}
+
+func underSourceRoot(name string) bool {
+	for _, r := range sourceRoots {
+		if strings.HasPrefix(name, r) {
+			return true
+		}
+	}
+	return false
+}
+
+func isArchive(base string) bool {
+	for _, ext := range sourceArchives {
+		if strings.HasSuffix(base, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+func quote(step string) string {
+	if len(step) > maxEvidence {
+		step = step[:maxEvidence] + "..."
+	}
+	return "history: " + step
+}
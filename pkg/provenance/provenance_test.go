@@ -0,0 +1,100 @@
+package provenance
+
+import (
+	"archive/tar"
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aquasecurity/binfinder/pkg/rootfs"
+)
+
+func loadFS(t *testing.T, files map[string]string) *rootfs.FS {
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	for name, content := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0755, Typeflag: tar.TypeReg, Size: int64(len(content))}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	fs, err := rootfs.Load(buf, "")
+	require.NoError(t, err)
+	return fs
+}
+
+func TestStep(t *testing.T) {
+	tests := []struct {
+		createdBy string
+		want      string
+	}{
+		{createdBy: "/bin/sh -c #(nop) COPY file:3ab1 in /usr/local/bin/ ", want: "COPY file:3ab1 in /usr/local/bin/"},
+		{createdBy: "/bin/sh -c set -eux; curl -fsSL https://example.com/tool -o /usr/local/bin/tool", want: "set -eux; curl -fsSL https://example.com/tool -o /usr/local/bin/tool"},
+		{createdBy: "COPY /out/app /usr/local/bin/app # buildkit", want: "COPY /out/app /usr/local/bin/app"},
+		{createdBy: "RUN /bin/sh -c make install # buildkit", want: "RUN /bin/sh -c make install"},
+	}
+	for _, tc := range tests {
+		assert.Equal(t, tc.want, Step(tc.createdBy), tc.createdBy)
+	}
+}
+
+func TestClassifier_Classify(t *testing.T) {
+	fs := loadFS(t, map[string]string{
+		"usr/local/bin/app":                              "elf",
+		"usr/local/bin/tool":                             "elf",
+		"usr/local/bin/redis-server":                     "elf",
+		"usr/local/bin/jq":                               "elf",
+		"usr/local/bin/mystery":                          "elf",
+		"usr/local/sbin/daemon":                          "elf",
+		"usr/local/lib/node_modules/esbuild/bin/esbuild": "elf",
+		"usr/src/redis/Makefile":                         "all:",
+		"usr/src/daemon/install_manifest.txt":            "/usr/local/sbin/daemon\n",
+		"tmp/jq-1.6.tar.gz":                              "gz",
+	})
+	defer fs.Close()
+
+	c := NewClassifier([]string{
+		"/bin/sh -c #(nop)  CMD [\"app\"]",
+		"COPY --from=builder /out/app /usr/local/bin/app # buildkit",
+		"/bin/sh -c curl -fsSL https://example.com/tool -o /usr/local/bin/tool && chmod +x /usr/local/bin/tool",
+		"/bin/sh -c cd /usr/src/redis && make && make install",
+		"/bin/sh -c #(nop) COPY file:3ab1 in /usr/local/bin/ ",
+		"/bin/sh -c #(nop) ADD file:9f2c in / ",
+	}, fs, nil)
+
+	tests := []struct {
+		binary string
+		want   Provenance
+	}{
+		{binary: "/usr/local/bin/app", want: Provenance{Method: CopiedFromStage,
+			Evidence: []string{"history: COPY --from=builder /out/app /usr/local/bin/app"}}},
+		{binary: "/usr/local/bin/tool", want: Provenance{Method: Downloaded,
+			Evidence: []string{"history: curl -fsSL https://example.com/tool -o /usr/local/bin/tool && chmod +x /usr/local/bin/tool"}}},
+		{binary: "/usr/local/bin/redis-server", want: Provenance{Method: BuiltFromSource,
+			Evidence: []string{"source /usr/src/redis present"}}},
+		{binary: "/usr/local/bin/jq", want: Provenance{Method: Unknown,
+			Evidence: []string{"history: COPY file:3ab1 in /usr/local/bin/"}}},
+		{binary: "/usr/local/sbin/daemon", want: Provenance{Method: BuiltFromSource,
+			Evidence: []string{"listed in /usr/src/daemon/install_manifest.txt"}}},
+		{binary: "/usr/local/lib/node_modules/esbuild/bin/esbuild", want: Provenance{Method: LanguageToolchain,
+			Evidence: []string{"installed under node_modules"}}},
+		{binary: "/opt/mystery", want: Provenance{Method: Unknown}},
+	}
+	for _, tc := range tests {
+		assert.Equal(t, tc.want, c.Classify(tc.binary), tc.binary)
+	}
+
+	// the build traces out of the scan scope are not considered
+	c = NewClassifier(nil, fs, func(name string) bool { return strings.HasPrefix(name, "/usr/src/") })
+	assert.Equal(t, Provenance{Method: Unknown}, c.Classify("/usr/local/sbin/daemon"))
+	assert.Equal(t, Provenance{Method: Unknown}, c.Classify("/usr/local/bin/redis-server"))
+}
+
+func TestQuote(t *testing.T) {
+	long := strings.Repeat("x", maxEvidence+10)
+	assert.Equal(t, "history: "+long[:maxEvidence]+"...", quote(long))
+	assert.Equal(t, "history: make install", quote("make install"))
+}
@@ -0,0 +1,330 @@
+package reach
+
+import (
+	"bufio"
+	"bytes"
+	"path"
+	"strings"
+
+	"github.com/aquasecurity/binfinder/pkg/rootfs"
+)
+
+// How a binary is reachable
+const (
+	Entrypoint   = "entrypoint"
+	Scheduled    = "scheduled"
+	Service      = "service"
+	OnPath       = "on-path"
+	Unreferenced = "unreferenced"
+)
+
+// maxScriptDepth is how many levels of scripts running scripts are followed
+const maxScriptDepth = 3
+
+var (
+	// system crontabs hold a user field before the command, user crontabs do not
+	systemCrontabs = []string{"/etc/crontab"}
+	systemCronDirs = []string{"/etc/cron.d"}
+	userCronDirs   = []string{"/var/spool/cron/crontabs", "/var/spool/cron", "/etc/crontabs"}
+	cronScriptDirs = []string{"/etc/cron.hourly", "/etc/cron.daily", "/etc/cron.weekly", "/etc/cron.monthly",
+		"/etc/periodic/15min", "/etc/periodic/hourly", "/etc/periodic/daily", "/etc/periodic/weekly", "/etc/periodic/monthly"}
+
+	initDirs        = []string{"/etc/init.d", "/etc/rc.d/init.d"}
+	systemdDirs     = []string{"/etc/systemd/system", "/lib/systemd/system", "/usr/lib/systemd/system"}
+	supervisorFiles = []string{"/etc/supervisord.conf", "/etc/supervisor/supervisord.conf"}
+	supervisorDirs  = []string{"/etc/supervisor/conf.d", "/etc/supervisord.d"}
+)
+
+// shellKeywords and command prefixes that are followed by the command they run
+var shellKeywords = map[string]bool{
+	"if": true, "then": true, "else": true, "elif": true, "fi": true, "do": true, "done": true, "while": true,
+	"until": true, "for": true, "case": true, "esac": true, "!": true, "{": true, "}": true, "exec": true,
+	"nohup": true, "env": true, "command": true, "time": true, "sudo": true, "nice": true, "setsid": true,
+}
+
+var shells = map[string]bool{"sh": true, "bash": true, "ash": true, "dash": true, "zsh": true, "ksh": true}
+
+// Analyzer finds how the binaries of an image filesystem are run
+type Analyzer struct {
+	FS *rootfs.FS
+	// Path are the directories of the PATH of the image
+	Path []string
+}
+
+// Tags returns how each binary is reachable: run by the image entrypoint and
+// command, scheduled by cron, started as a service, or only found on PATH
+func (a *Analyzer) Tags(entrypoint, cmd []string, binaries []string) map[string][]string {
+	refs := map[string]map[string]bool{
+		Entrypoint: a.entrypointRefs(entrypoint, cmd),
+		Scheduled:  a.scheduledRefs(),
+		Service:    a.serviceRefs(),
+	}
+	onPath := make(map[string]bool)
+	for _, d := range a.Path {
+		onPath[d] = true
+	}
+	tags := make(map[string][]string)
+	for _, b := range binaries {
+		resolved := a.resolve(b)
+		for _, kind := range []string{Entrypoint, Scheduled, Service} {
+			if refs[kind][resolved] {
+				tags[b] = append(tags[b], kind)
+			}
+		}
+		if onPath[path.Dir(b)] {
+			tags[b] = append(tags[b], OnPath)
+		}
+		if len(tags[b]) == 0 {
+			tags[b] = []string{Unreferenced}
+		}
+	}
+	return tags
+}
+
+func (a *Analyzer) resolve(name string) string {
+	r, err := a.FS.Resolve(name)
+	if err != nil {
+		return name
+	}
+	return r
+}
+
+// lookPath returns the resolved path of a command word, searched on PATH
+// when it is not a path
+func (a *Analyzer) lookPath(word string, dir string) (string, bool) {
+	if word == "" {
+		return "", false
+	}
+	if strings.Contains(word, "/") {
+		if !path.IsAbs(word) {
+			word = path.Join(dir, word)
+		}
+		if !a.FS.Exists(word) {
+			return "", false
+		}
+		return a.resolve(word), true
+	}
+	for _, d := range a.Path {
+		if p := path.Join(d, word); a.FS.Exists(p) {
+			return a.resolve(p), true
+		}
+	}
+	return "", false
+}
+
+// references collects the files run by commands, following the scripts they run
+type references struct {
+	a    *Analyzer
+	refs map[string]bool
+}
+
+func (a *Analyzer) newReferences() *references {
+	return &references{a: a, refs: make(map[string]bool)}
+}
+
+// add records the command words of a shell text
+func (r *references) add(text string, dir string, depth int) {
+	for _, word := range Commands(text) {
+		r.addWord(word, dir, depth)
+	}
+}
+
+func (r *references) addWord(word string, dir string, depth int) {
+	p, ok := r.a.lookPath(word, dir)
+	if !ok || r.refs[p] {
+		return
+	}
+	r.refs[p] = true
+	r.addScript(p, depth)
+}
+
+// addScript records the interpreter and commands of a script
+func (r *references) addScript(p string, depth int) {
+	if depth >= maxScriptDepth {
+		return
+	}
+	content, err := r.a.FS.ReadFile(p)
+	if err != nil || !bytes.HasPrefix(content, []byte("#!")) {
+		return
+	}
+	line := content[2:]
+	if i := bytes.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	if f := strings.Fields(string(line)); len(f) > 0 {
+		r.addWord(f[0], "/", depth+1)
+		if path.Base(f[0]) == "env" && len(f) > 1 {
+			r.addWord(f[1], "/", depth+1)
+		}
+	}
+	r.add(string(content), path.Dir(p), depth+1)
+}
+
+func (a *Analyzer) entrypointRefs(entrypoint, cmd []string) map[string]bool {
+	r := a.newReferences()
+	argv := append(append([]string{}, entrypoint...), cmd...)
+	if len(argv) == 0 {
+		return r.refs
+	}
+	// sh -c "command": the shell and the commands of its script
+	if shells[path.Base(argv[0])] && len(argv) > 2 && argv[1] == "-c" {
+		r.addWord(argv[0], "/", 0)
+		r.add(argv[2], "/", 0)
+		return r.refs
+	}
+	// exec form: the program, and the arguments wrappers such as gosu or tini run
+	for _, arg := range argv {
+		if !strings.HasPrefix(arg, "-") {
+			r.addWord(arg, "/", 0)
+		}
+	}
+	return r.refs
+}
+
+func (a *Analyzer) scheduledRefs() map[string]bool {
+	r := a.newReferences()
+	for _, f := range systemCrontabs {
+		a.addCrontab(r, f, 6)
+	}
+	for _, d := range systemCronDirs {
+		for _, f := range a.FS.ReadDir(d) {
+			a.addCrontab(r, f, 6)
+		}
+	}
+	for _, d := range userCronDirs {
+		for _, f := range a.FS.ReadDir(d) {
+			a.addCrontab(r, f, 5)
+		}
+	}
+	for _, d := range cronScriptDirs {
+		for _, f := range a.FS.ReadDir(d) {
+			r.addWord(f, "/", 0)
+		}
+	}
+	return r.refs
+}
+
+// addCrontab records the commands of a crontab whose lines have fields fields before the command
+func (a *Analyzer) addCrontab(r *references, name string, fields int) {
+	content, err := a.FS.ReadFile(name)
+	if err != nil {
+		return
+	}
+	s := bufio.NewScanner(bytes.NewReader(content))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		f := strings.Fields(line)
+		skip := fields
+		if strings.HasPrefix(f[0], "@") {
+			// @reboot, @daily... replace the five time fields
+			skip = fields - 4
+		} else if strings.Contains(f[0], "=") {
+			// environment assignment
+			continue
+		}
+		if len(f) <= skip {
+			continue
+		}
+		r.add(strings.Join(f[skip:], " "), "/", 0)
+	}
+}
+
+func (a *Analyzer) serviceRefs() map[string]bool {
+	r := a.newReferences()
+	for _, d := range initDirs {
+		for _, f := range a.FS.ReadDir(d) {
+			r.addWord(f, "/", 0)
+		}
+	}
+	for _, d := range systemdDirs {
+		for _, f := range a.FS.ReadDir(d) {
+			if strings.HasSuffix(f, ".service") {
+				a.addConfig(r, f, func(key string) bool { return strings.HasPrefix(key, "Exec") }, "-@:+!")
+			}
+		}
+	}
+	files := append([]string{}, supervisorFiles...)
+	for _, d := range supervisorDirs {
+		files = append(files, a.FS.ReadDir(d)...)
+	}
+	for _, f := range files {
+		a.addConfig(r, f, func(key string) bool { return key == "command" }, "")
+	}
+	return r.refs
+}
+
+// addConfig records the commands of the key=value lines of an ini style file
+// whose key is accepted, after trimming the prefix characters of the value
+func (a *Analyzer) addConfig(r *references, name string, accept func(string) bool, prefixes string) {
+	content, err := a.FS.ReadFile(name)
+	if err != nil {
+		return
+	}
+	s := bufio.NewScanner(bytes.NewReader(content))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		i := strings.IndexByte(line, '=')
+		if i < 0 || !accept(strings.TrimSpace(line[:i])) {
+			continue
+		}
+		r.add(strings.TrimLeft(strings.TrimSpace(line[i+1:]), prefixes), "/", 0)
+	}
+}
+
+// Commands returns the words of a shell text that may name a program: the
+// first word of each command, and the absolute paths found anywhere, also
+// as the value of an assignment such as DAEMON=/usr/sbin/daemon
+func Commands(text string) []string {
+	var words []string
+	seen := make(map[string]bool)
+	add := func(w string) {
+		w = strings.Trim(w, `"'`)
+		if w != "" && !seen[w] && !strings.ContainsAny(w, "$*?`") {
+			seen[w] = true
+			words = append(words, w)
+		}
+	}
+	s := bufio.NewScanner(strings.NewReader(text))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		for _, segment := range splitCommands(line) {
+			command := true
+			for _, w := range strings.Fields(segment) {
+				if i := strings.IndexByte(w, '='); i > 0 && !strings.HasPrefix(w, "-") {
+					// an assignment, also before the command: only its value may be a path
+					if v := w[i+1:]; path.IsAbs(strings.Trim(v, `"'`)) {
+						add(v)
+					}
+					continue
+				}
+				if command {
+					if shellKeywords[w] {
+						continue
+					}
+					add(w)
+					command = false
+					continue
+				}
+				if path.IsAbs(strings.Trim(w, `"'`)) {
+					add(w)
+				}
+			}
+		}
+	}
+	return words
+}
+
+// splitCommands splits a shell line on the operators separating commands
+func splitCommands(line string) []string {
+	if i := strings.Index(line, " #"); i >= 0 {
+		line = line[:i]
+	}
+	return strings.FieldsFunc(strings.NewReplacer("&&", ";", "||", ";", "$(", ";", "`", ";").Replace(line),
+		func(r rune) bool { return r == ';' || r == '|' || r == '&' || r == '(' || r == ')' })
+}
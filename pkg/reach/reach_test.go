@@ -0,0 +1,107 @@
+package reach
+
+import (
+	"archive/tar"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aquasecurity/binfinder/pkg/rootfs"
+)
+
+type file struct {
+	name     string
+	content  string
+	linkname string
+	dir      bool
+}
+
+func loadFS(t *testing.T, files []file) *rootfs.FS {
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	for _, f := range files {
+		hdr := &tar.Header{Name: f.name, Mode: 0755, Typeflag: tar.TypeReg, Size: int64(len(f.content))}
+		switch {
+		case f.dir:
+			hdr.Typeflag = tar.TypeDir
+		case f.linkname != "":
+			hdr.Typeflag = tar.TypeSymlink
+			hdr.Linkname = f.linkname
+		}
+		require.NoError(t, tw.WriteHeader(hdr))
+		_, err := tw.Write([]byte(f.content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	fs, err := rootfs.Load(buf, "")
+	require.NoError(t, err)
+	return fs
+}
+
+func TestCommands(t *testing.T) {
+	testCases := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{name: "simple", text: "redis-server --port 6379", want: []string{"redis-server"}},
+		{name: "pipeline and lists", text: "cd /data && gosu app backup | gzip > out.gz; true", want: []string{"cd", "/data", "gosu", "gzip", "true"}},
+		{name: "keywords and exec", text: "if [ -x /opt/app/run ]; then\n  exec /opt/app/run \"$@\"\nfi\n", want: []string{"[", "/opt/app/run"}},
+		{name: "assignments", text: "DAEMON=/usr/sbin/agent\nLANG=C app --flag=value", want: []string{"/usr/sbin/agent", "app"}},
+		{name: "comments and variables", text: "# run /usr/bin/old\n$DAEMON start # /usr/bin/ignored", want: nil},
+		{name: "command substitution", text: "VERSION=$(/usr/local/bin/app --version)", want: []string{"/usr/local/bin/app"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, Commands(tc.text))
+		})
+	}
+}
+
+func TestAnalyzer_Tags(t *testing.T) {
+	fs := loadFS(t, []file{
+		{name: "bin", linkname: "usr/bin"},
+		{name: "usr/bin/sh", content: "\x7fELF"},
+		{name: "usr/local/bin/docker-entrypoint.sh", content: "#!/bin/sh\nset -e\nexec gosu redis \"$@\"\n"},
+		{name: "usr/local/bin/gosu", content: "\x7fELF"},
+		{name: "usr/local/bin/redis-server", content: "\x7fELF"},
+		{name: "usr/local/bin/redis-cli", content: "\x7fELF"},
+		{name: "usr/local/bin/backup", content: "\x7fELF"},
+		{name: "usr/local/bin/rotate", content: "\x7fELF"},
+		{name: "usr/local/bin/healthz", content: "\x7fELF"},
+		{name: "opt/agent/bin/agent", content: "\x7fELF"},
+		{name: "opt/agent/bin/collector", content: "\x7fELF"},
+		{name: "opt/agent/bin/watchdog", content: "\x7fELF"},
+		{name: "opt/tools/unused", content: "\x7fELF"},
+		{name: "etc/crontab", content: "SHELL=/bin/sh\n# m h dom mon dow user command\n17 * * * * root backup --all\n"},
+		{name: "etc/cron.daily/rotate", content: "#!/bin/sh\n/usr/local/bin/rotate /var/log\n"},
+		{name: "etc/init.d/agent", content: "#!/bin/sh\nDAEMON=/opt/agent/bin/agent\nstart-stop-daemon --start --exec $DAEMON\n"},
+		{name: "lib/systemd/system/collector.service", content: "[Service]\nExecStartPre=-/bin/mkdir -p /run/collector\nExecStart=/opt/agent/bin/collector --foreground\n"},
+		{name: "etc/supervisor/conf.d/watchdog.conf", content: "[program:watchdog]\ncommand=/opt/agent/bin/watchdog -c /etc/watchdog.conf\n"},
+	})
+	defer fs.Close()
+
+	a := Analyzer{FS: fs, Path: []string{"/usr/local/bin", "/usr/bin", "/bin"}}
+	binaries := []string{"/usr/local/bin/gosu", "/usr/local/bin/redis-server", "/usr/local/bin/redis-cli",
+		"/usr/local/bin/backup", "/usr/local/bin/rotate", "/opt/agent/bin/agent", "/opt/agent/bin/collector",
+		"/opt/agent/bin/watchdog", "/opt/tools/unused", "/bin/sh"}
+	assert.Equal(t, map[string][]string{
+		"/usr/local/bin/gosu":         {Entrypoint, OnPath},
+		"/usr/local/bin/redis-server": {Entrypoint, OnPath},
+		"/usr/local/bin/redis-cli":    {OnPath},
+		"/usr/local/bin/backup":       {Scheduled, OnPath},
+		"/usr/local/bin/rotate":       {Scheduled, OnPath},
+		"/opt/agent/bin/agent":        {Service},
+		"/opt/agent/bin/collector":    {Service},
+		"/opt/agent/bin/watchdog":     {Service},
+		"/opt/tools/unused":           {Unreferenced},
+		"/bin/sh":                     {Entrypoint, Scheduled, Service, OnPath},
+	}, a.Tags([]string{"docker-entrypoint.sh"}, []string{"redis-server"}, binaries))
+
+	// shell form
+	tags := a.Tags([]string{"/bin/sh", "-c", "healthz && exec redis-server /etc/redis.conf"}, nil, []string{"/usr/local/bin/healthz", "/usr/local/bin/gosu"})
+	assert.Equal(t, []string{Entrypoint, OnPath}, tags["/usr/local/bin/healthz"])
+	assert.Equal(t, []string{OnPath}, tags["/usr/local/bin/gosu"])
+}
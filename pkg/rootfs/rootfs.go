@@ -0,0 +1,231 @@
+package rootfs
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+const maxSymlinkDepth = 40
+
+// Entry describes a single file of the exported filesystem
+type Entry struct {
+	Header *tar.Header
+	offset int64
+}
+
+// FS is an indexed, read-only snapshot of a container root filesystem
+// backed by the tar stream produced by `docker export`
+type FS struct {
+	file    *os.File
+	temp    bool
+	entries map[string]*Entry
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// Load copies the tar stream r into a temporary file inside dir and indexes it
+func Load(r io.Reader, dir string) (*FS, error) {
+	f, err := ioutil.TempFile(dir, "binfinder-rootfs-*.tar")
+	if err != nil {
+		return nil, err
+	}
+	if _, err = io.Copy(f, r); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	fs, err := index(f)
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	fs.temp = true
+	return fs, nil
+}
+
+// Open indexes an existing tar archive of a root filesystem
+func Open(name string) (*FS, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	fs, err := index(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return fs, nil
+}
+
+func index(f *os.File) (*FS, error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	fs := &FS{file: f, entries: make(map[string]*Entry)}
+	cr := &countingReader{r: f}
+	tr := tar.NewReader(cr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading filesystem archive: %v", err)
+		}
+		fs.entries[Clean(hdr.Name)] = &Entry{Header: hdr, offset: cr.n}
+	}
+	return fs, nil
+}
+
+// Close releases the archive, removing it if it was created by Load
+func (fs *FS) Close() error {
+	err := fs.file.Close()
+	if fs.temp {
+		if rerr := os.Remove(fs.file.Name()); err == nil {
+			err = rerr
+		}
+	}
+	return err
+}
+
+// Clean turns an archive member name or a path into an absolute, clean path
+func Clean(name string) string {
+	return path.Clean("/" + strings.TrimPrefix(name, "./"))
+}
+
+// Lstat returns the entry stored for name without following a final symlink
+func (fs *FS) Lstat(name string) (*Entry, bool) {
+	e, ok := fs.entries[Clean(name)]
+	return e, ok
+}
+
+// Stat returns the entry for name, following symlinks
+func (fs *FS) Stat(name string) (*Entry, bool) {
+	p, err := fs.Resolve(name)
+	if err != nil {
+		return nil, false
+	}
+	return fs.Lstat(p)
+}
+
+// Resolve follows symlinks in every component of name and returns the
+// path of the entry it finally refers to
+func (fs *FS) Resolve(name string) (string, error) {
+	return fs.resolve(Clean(name), 0)
+}
+
+func (fs *FS) resolve(name string, depth int) (string, error) {
+	if depth > maxSymlinkDepth {
+		return "", fmt.Errorf("%v: too many levels of symbolic links", name)
+	}
+	resolved := "/"
+	parts := strings.Split(strings.TrimPrefix(name, "/"), "/")
+	for i, part := range parts {
+		if part == "" {
+			continue
+		}
+		p := path.Join(resolved, part)
+		e, ok := fs.entries[p]
+		if !ok {
+			if i == len(parts)-1 {
+				return p, nil
+			}
+			resolved = p
+			continue
+		}
+		if e.Header.Typeflag == tar.TypeSymlink {
+			target := e.Header.Linkname
+			if !path.IsAbs(target) {
+				target = path.Join(resolved, target)
+			}
+			rest := strings.Join(parts[i+1:], "/")
+			return fs.resolve(path.Join(target, rest), depth+1)
+		}
+		resolved = p
+	}
+	return resolved, nil
+}
+
+// Exists reports whether name, with symlinks followed, is present
+func (fs *FS) Exists(name string) bool {
+	_, ok := fs.Stat(name)
+	return ok
+}
+
+// Open returns a reader for the content of the regular file name
+func (fs *FS) Open(name string) (*io.SectionReader, error) {
+	return fs.open(name, 0)
+}
+
+func (fs *FS) open(name string, depth int) (*io.SectionReader, error) {
+	if depth > maxSymlinkDepth {
+		return nil, fmt.Errorf("%v: too many levels of hard links", name)
+	}
+	e, ok := fs.Stat(name)
+	if !ok {
+		return nil, fmt.Errorf("%v: %w", name, os.ErrNotExist)
+	}
+	if e.Header.Typeflag == tar.TypeLink {
+		return fs.open(e.Header.Linkname, depth+1)
+	}
+	if e.Header.Typeflag != tar.TypeReg && e.Header.Typeflag != tar.TypeRegA {
+		return nil, fmt.Errorf("%v: %w", name, errNotRegular)
+	}
+	return io.NewSectionReader(fs.file, e.offset, e.Header.Size), nil
+}
+
+var errNotRegular = errors.New("not a regular file")
+
+// ReadFile returns the content of the regular file name
+func (fs *FS) ReadFile(name string) ([]byte, error) {
+	r, err := fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(r)
+}
+
+// ReadDir returns the sorted paths of the direct children of dir
+func (fs *FS) ReadDir(dir string) []string {
+	p, err := fs.Resolve(dir)
+	if err != nil {
+		return nil
+	}
+	var children []string
+	for name := range fs.entries {
+		if name != "/" && path.Dir(name) == p {
+			children = append(children, name)
+		}
+	}
+	sort.Strings(children)
+	return children
+}
+
+// Walk calls fn for every entry of the filesystem in lexical order
+func (fs *FS) Walk(fn func(name string, e *Entry)) {
+	names := make([]string, 0, len(fs.entries))
+	for name := range fs.entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fn(name, fs.entries[name])
+	}
+}
@@ -0,0 +1,87 @@
+package rootfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func buildArchive(t *testing.T) *bytes.Buffer {
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	entries := []struct {
+		hdr     tar.Header
+		content string
+	}{
+		{hdr: tar.Header{Name: "usr/", Typeflag: tar.TypeDir, Mode: 0755}},
+		{hdr: tar.Header{Name: "usr/bin/", Typeflag: tar.TypeDir, Mode: 0755}},
+		{hdr: tar.Header{Name: "usr/bin/python3.9", Typeflag: tar.TypeReg, Mode: 0755}, content: "\x7fELFpython"},
+		{hdr: tar.Header{Name: "usr/bin/python3", Typeflag: tar.TypeSymlink, Linkname: "python3.9"}},
+		{hdr: tar.Header{Name: "usr/bin/py", Typeflag: tar.TypeLink, Linkname: "usr/bin/python3.9"}},
+		{hdr: tar.Header{Name: "bin", Typeflag: tar.TypeSymlink, Linkname: "usr/bin"}},
+		{hdr: tar.Header{Name: "loop", Typeflag: tar.TypeSymlink, Linkname: "/loop"}},
+		{hdr: tar.Header{Name: "usr/bin/a", Typeflag: tar.TypeLink, Linkname: "usr/bin/b"}},
+		{hdr: tar.Header{Name: "usr/bin/b", Typeflag: tar.TypeLink, Linkname: "usr/bin/a"}},
+	}
+	for _, e := range entries {
+		hdr := e.hdr
+		hdr.Size = int64(len(e.content))
+		require.NoError(t, tw.WriteHeader(&hdr))
+		_, err := tw.Write([]byte(e.content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	return buf
+}
+
+func TestFS(t *testing.T) {
+	fs, err := Load(buildArchive(t), "")
+	require.NoError(t, err)
+	name := fs.file.Name()
+	defer func() {
+		assert.NoError(t, fs.Close())
+		_, err := os.Stat(name)
+		assert.True(t, os.IsNotExist(err), "temporary archive should be removed")
+	}()
+
+	testCases := []struct {
+		name        string
+		path        string
+		wantPath    string
+		wantContent string
+		wantErr     bool
+	}{
+		{name: "regular file", path: "/usr/bin/python3.9", wantPath: "/usr/bin/python3.9", wantContent: "\x7fELFpython"},
+		{name: "relative symlink", path: "/usr/bin/python3", wantPath: "/usr/bin/python3.9", wantContent: "\x7fELFpython"},
+		{name: "symlinked directory", path: "/bin/python3", wantPath: "/usr/bin/python3.9", wantContent: "\x7fELFpython"},
+		{name: "hard link", path: "/usr/bin/py", wantPath: "/usr/bin/py", wantContent: "\x7fELFpython"},
+		{name: "missing file", path: "/usr/bin/ruby", wantPath: "/usr/bin/ruby", wantErr: true},
+		{name: "directory", path: "/usr/bin", wantPath: "/usr/bin", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := fs.Resolve(tc.path)
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantPath, got)
+
+			b, err := fs.ReadFile(tc.path)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantContent, string(b))
+		})
+	}
+
+	_, err = fs.Resolve("/loop/x")
+	assert.Error(t, err)
+	_, err = fs.Open("/usr/bin/a")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "too many levels of hard links")
+	assert.Equal(t, []string{"/usr/bin/a", "/usr/bin/b", "/usr/bin/py", "/usr/bin/python3", "/usr/bin/python3.9"}, fs.ReadDir("/bin"))
+}
@@ -0,0 +1,154 @@
+package rules
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// condition evaluates a rule condition given the strings found and the number of strings of the rule
+type condition func(found map[string]bool, total int) bool
+
+// parseCondition parses a YARA style boolean expression over string IDs:
+//
+//	$a and ($b or not $c)
+//	any of them | all of them | none of them | 2 of them
+func parseCondition(expr string, ids map[string]bool) (condition, error) {
+	p := &conditionParser{tokens: tokenize(expr), ids: ids}
+	c, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q", p.tokens[p.pos])
+	}
+	return c, nil
+}
+
+func tokenize(expr string) []string {
+	var tokens []string
+	var cur strings.Builder
+	flush := func() {
+		if cur.Len() > 0 {
+			tokens = append(tokens, cur.String())
+			cur.Reset()
+		}
+	}
+	for _, r := range expr {
+		switch {
+		case unicode.IsSpace(r):
+			flush()
+		case r == '(' || r == ')':
+			flush()
+			tokens = append(tokens, string(r))
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	flush()
+	return tokens
+}
+
+type conditionParser struct {
+	tokens []string
+	pos    int
+	ids    map[string]bool
+}
+
+func (p *conditionParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *conditionParser) next() string {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *conditionParser) or() (condition, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "or" {
+		p.next()
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(found map[string]bool, total int) bool { return l(found, total) || right(found, total) }
+	}
+	return left, nil
+}
+
+func (p *conditionParser) and() (condition, error) {
+	left, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "and" {
+		p.next()
+		right, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(found map[string]bool, total int) bool { return l(found, total) && right(found, total) }
+	}
+	return left, nil
+}
+
+func (p *conditionParser) not() (condition, error) {
+	if p.peek() != "not" {
+		return p.primary()
+	}
+	p.next()
+	c, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	return func(found map[string]bool, total int) bool { return !c(found, total) }, nil
+}
+
+func (p *conditionParser) primary() (condition, error) {
+	t := p.next()
+	switch {
+	case t == "":
+		return nil, fmt.Errorf("unexpected end of condition")
+	case t == "(":
+		c, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, fmt.Errorf("missing )")
+		}
+		return c, nil
+	case strings.HasPrefix(t, "$"):
+		if !p.ids[t] {
+			return nil, fmt.Errorf("undefined string %v", t)
+		}
+		return func(found map[string]bool, total int) bool { return found[t] }, nil
+	}
+	if p.next() != "of" || p.next() != "them" {
+		return nil, fmt.Errorf("unexpected %q", t)
+	}
+	switch t {
+	case "any":
+		return func(found map[string]bool, total int) bool { return len(found) > 0 }, nil
+	case "all":
+		return func(found map[string]bool, total int) bool { return len(found) == total }, nil
+	case "none":
+		return func(found map[string]bool, total int) bool { return len(found) == 0 }, nil
+	}
+	n, err := strconv.Atoi(t)
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("invalid count %q", t)
+	}
+	return func(found map[string]bool, total int) bool { return len(found) >= n }, nil
+}
@@ -0,0 +1,244 @@
+package rules
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Severities of the rules
+const (
+	SeverityLow      = "low"
+	SeverityMedium   = "medium"
+	SeverityHigh     = "high"
+	SeverityCritical = "critical"
+)
+
+// DefaultCondition matches when any string of the rule is found
+const DefaultCondition = "any of them"
+
+// Rule describes content that binfinder looks for in unmanaged binaries
+type Rule struct {
+	Name        string
+	Description string
+	Severity    string
+	// Meta is free form information about the rule, e.g. author or reference
+	Meta      map[string]string
+	Strings   []String
+	Condition string
+
+	cond condition
+}
+
+// String is a pattern of a rule, exactly one of Text, Hex or Regex is set
+type String struct {
+	ID string
+	// Text is a literal string
+	Text string
+	// Hex are space separated bytes, ?? matches any byte
+	Hex string
+	// Regex is a regular expression
+	Regex string
+	// NoCase makes Text and Regex case insensitive
+	NoCase bool
+
+	matcher func([]byte) bool
+}
+
+// Hit is a rule matching a file
+type Hit struct {
+	Rule        string
+	Severity    string
+	Description string            `json:",omitempty"`
+	Meta        map[string]string `json:",omitempty"`
+	// Strings are the IDs of the strings found in the file, none for negated conditions
+	Strings []string `json:",omitempty"`
+}
+
+// Set is a compiled set of rules
+type Set struct {
+	Rules []*Rule
+}
+
+// Load reads and compiles every *.json rule file of dir, each holding a list of rules
+func Load(dir string) (*Set, error) {
+	names, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	s := &Set{}
+	for _, name := range names {
+		b, err := ioutil.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		rules, err := Parse(b)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", name, err)
+		}
+		s.Rules = append(s.Rules, rules...)
+	}
+	return s, nil
+}
+
+// Parse compiles the JSON list of rules in b
+func Parse(b []byte) ([]*Rule, error) {
+	var rules []*Rule
+	if err := json.Unmarshal(b, &rules); err != nil {
+		return nil, fmt.Errorf("invalid rules: %v", err)
+	}
+	for _, r := range rules {
+		if err := r.compile(); err != nil {
+			return nil, fmt.Errorf("rule %v: %v", r.Name, err)
+		}
+	}
+	return rules, nil
+}
+
+func (r *Rule) compile() error {
+	if r.Name == "" {
+		return fmt.Errorf("missing name")
+	}
+	switch r.Severity {
+	case "":
+		r.Severity = SeverityMedium
+	case SeverityLow, SeverityMedium, SeverityHigh, SeverityCritical:
+	default:
+		return fmt.Errorf("unknown severity: %v", r.Severity)
+	}
+	if len(r.Strings) == 0 {
+		return fmt.Errorf("no strings")
+	}
+	ids := make(map[string]bool)
+	for i := range r.Strings {
+		s := &r.Strings[i]
+		if s.ID == "" {
+			s.ID = fmt.Sprintf("$s%d", i)
+		}
+		if !strings.HasPrefix(s.ID, "$") {
+			return fmt.Errorf("string ID %v does not start with $", s.ID)
+		}
+		if ids[s.ID] {
+			return fmt.Errorf("duplicate string %v", s.ID)
+		}
+		ids[s.ID] = true
+		if err := s.compile(); err != nil {
+			return fmt.Errorf("string %v: %v", s.ID, err)
+		}
+	}
+	if r.Condition == "" {
+		r.Condition = DefaultCondition
+	}
+	var err error
+	if r.cond, err = parseCondition(r.Condition, ids); err != nil {
+		return fmt.Errorf("condition: %v", err)
+	}
+	return nil
+}
+
+func (s *String) compile() error {
+	set := 0
+	for _, v := range []string{s.Text, s.Hex, s.Regex} {
+		if v != "" {
+			set++
+		}
+	}
+	if set != 1 {
+		return fmt.Errorf("exactly one of Text, Hex or Regex must be set")
+	}
+	switch {
+	case s.Text != "" && s.NoCase:
+		s.matcher = regexp.MustCompile("(?i)" + regexp.QuoteMeta(s.Text)).Match
+	case s.Text != "":
+		text := []byte(s.Text)
+		s.matcher = func(b []byte) bool { return bytes.Contains(b, text) }
+	case s.Hex != "":
+		pattern, err := parseHex(s.Hex)
+		if err != nil {
+			return err
+		}
+		s.matcher = func(b []byte) bool { return matchHex(b, pattern) }
+	default:
+		expr := s.Regex
+		if s.NoCase {
+			expr = "(?i)" + expr
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return err
+		}
+		s.matcher = re.Match
+	}
+	return nil
+}
+
+// wildcard is a hex pattern byte matching any byte
+const wildcard = -1
+
+func parseHex(hex string) ([]int, error) {
+	var pattern []int
+	for _, f := range strings.Fields(hex) {
+		if f == "??" {
+			pattern = append(pattern, wildcard)
+			continue
+		}
+		v, err := strconv.ParseUint(f, 16, 8)
+		if err != nil || len(f) != 2 {
+			return nil, fmt.Errorf("invalid hex byte: %v", f)
+		}
+		pattern = append(pattern, int(v))
+	}
+	if len(pattern) == 0 || pattern[0] == wildcard {
+		return nil, fmt.Errorf("hex pattern must start with a byte")
+	}
+	return pattern, nil
+}
+
+func matchHex(b []byte, pattern []int) bool {
+	first := byte(pattern[0])
+	for i := 0; i+len(pattern) <= len(b); i++ {
+		j := bytes.IndexByte(b[i:len(b)-len(pattern)+1], first)
+		if j < 0 {
+			return false
+		}
+		i += j
+		matched := true
+		for k, p := range pattern[1:] {
+			if p != wildcard && b[i+k+1] != byte(p) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Match returns the hits of the rules of the set on the content of a file
+func (s *Set) Match(content []byte) []Hit {
+	var hits []Hit
+	for _, r := range s.Rules {
+		found := make(map[string]bool)
+		var ids []string
+		for _, str := range r.Strings {
+			if str.matcher(content) {
+				found[str.ID] = true
+				ids = append(ids, str.ID)
+			}
+		}
+		if !r.cond(found, len(r.Strings)) {
+			continue
+		}
+		hits = append(hits, Hit{Rule: r.Name, Severity: r.Severity, Description: r.Description, Meta: r.Meta, Strings: ids})
+	}
+	return hits
+}
@@ -0,0 +1,78 @@
+package shadow
+
+import (
+	"path"
+	"strings"
+)
+
+// DefaultPath is the PATH of images whose configuration does not set one,
+// the default of the docker daemon
+const DefaultPath = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+// Finding is an unmanaged binary found on PATH before a packaged command of the same name
+type Finding struct {
+	Binary string
+	// Shadows is the packaged command that is no longer run
+	Shadows string
+	Package string
+}
+
+// PathDirs returns the directories of the PATH variable of an image environment
+func PathDirs(env []string) []string {
+	value := DefaultPath
+	for _, e := range env {
+		if strings.HasPrefix(e, "PATH=") {
+			value = strings.TrimPrefix(e, "PATH=")
+		}
+	}
+	var dirs []string
+	for _, d := range strings.Split(value, ":") {
+		if d != "" {
+			dirs = append(dirs, path.Clean(d))
+		}
+	}
+	return dirs
+}
+
+// Image is what Find needs to know about the image filesystem
+type Image struct {
+	// Owner returns the package that installed a file, or an empty string
+	Owner func(name string) string
+	// Resolve returns the path of a file with symlinks resolved, or an error when it does not exist
+	Resolve func(name string) (string, error)
+}
+
+// Find returns the unmanaged binaries that shadow a packaged command found
+// later on PATH
+func Find(dirs []string, unmanaged []string, img Image) []Finding {
+	var findings []Finding
+	for _, b := range unmanaged {
+		position := -1
+		for i, d := range dirs {
+			if d == path.Dir(b) {
+				position = i
+				break
+			}
+		}
+		if position < 0 {
+			continue
+		}
+		resolved, err := img.Resolve(b)
+		if err != nil {
+			continue
+		}
+		for _, d := range dirs[position+1:] {
+			candidate := path.Join(d, path.Base(b))
+			r, err := img.Resolve(candidate)
+			// a directory linked to the one of the binary, e.g. /bin and /usr/bin with merged /usr
+			if err != nil || r == resolved {
+				continue
+			}
+			if pkg := img.Owner(candidate); pkg != "" {
+				findings = append(findings, Finding{Binary: b, Shadows: candidate, Package: pkg})
+				break
+			}
+		}
+	}
+	return findings
+}
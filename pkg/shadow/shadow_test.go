@@ -0,0 +1,49 @@
+package shadow
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPathDirs(t *testing.T) {
+	assert.Equal(t, []string{"/opt/app/bin", "/usr/local/bin", "/usr/bin"},
+		PathDirs([]string{"LANG=C.UTF-8", "PATH=/opt/app/bin/:/usr/local/bin::/usr/bin"}))
+	assert.Equal(t, strings.Split(DefaultPath, ":"), PathDirs([]string{"LANG=C.UTF-8"}))
+}
+
+func TestFind(t *testing.T) {
+	owners := map[string]string{
+		"/usr/bin/curl":   "curl",
+		"/usr/bin/python": "python-minimal",
+		"/bin/sh":         "dash",
+	}
+	files := map[string]string{
+		"/usr/local/bin/curl":   "/usr/local/bin/curl",
+		"/usr/local/bin/python": "/usr/local/bin/python",
+		"/usr/local/bin/jq":     "/usr/local/bin/jq",
+		"/usr/bin/curl":         "/usr/bin/curl",
+		"/usr/bin/python":       "/usr/bin/python",
+		"/opt/tool/curl":        "/opt/tool/curl",
+		// merged /usr: /bin links to /usr/bin
+		"/bin/sh":     "/usr/bin/sh",
+		"/usr/bin/sh": "/usr/bin/sh",
+	}
+	img := Image{
+		Owner: func(name string) string { return owners[name] },
+		Resolve: func(name string) (string, error) {
+			if r, ok := files[name]; ok {
+				return r, nil
+			}
+			return "", errors.New("not found")
+		},
+	}
+	dirs := []string{"/usr/local/bin", "/usr/bin", "/bin"}
+	unmanaged := []string{"/usr/local/bin/curl", "/usr/local/bin/jq", "/usr/local/bin/python", "/opt/tool/curl", "/usr/bin/sh"}
+	assert.Equal(t, []Finding{
+		{Binary: "/usr/local/bin/curl", Shadows: "/usr/bin/curl", Package: "curl"},
+		{Binary: "/usr/local/bin/python", Shadows: "/usr/bin/python", Package: "python-minimal"},
+	}, Find(dirs, unmanaged, img))
+}
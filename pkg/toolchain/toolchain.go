@@ -0,0 +1,156 @@
+package toolchain
+
+import (
+	"archive/tar"
+	"path"
+	"regexp"
+	"sort"
+
+	"github.com/aquasecurity/binfinder/pkg/rootfs"
+)
+
+// Kinds of build tools
+const (
+	Compiler  = "compiler"
+	BuildTool = "build-tool"
+)
+
+// Signature identifies a build tool by the names of its commands and the
+// directories of its installation
+type Signature struct {
+	Tool string
+	Kind string
+	// Commands matches the base name of the tool commands found in a bin or sbin directory
+	Commands *regexp.Regexp
+	// Dirs are path.Match patterns of the directories the tool installs, their whole content counts in its size
+	Dirs []string
+}
+
+// Signatures are the compilers and build tools that only a build needs
+var Signatures = []Signature{
+	{Tool: "gcc", Kind: Compiler, Commands: regexp.MustCompile(`^([a-z0-9_]+-[a-z0-9_]+-[a-z0-9_-]+-)?(gcc|g\+\+|c\+\+|cc|gfortran)(-[0-9.]+)?$`),
+		Dirs: []string{"/usr/lib/gcc", "/usr/libexec/gcc", "/usr/local/lib/gcc", "/usr/local/libexec/gcc"}},
+	{Tool: "clang", Kind: Compiler, Commands: regexp.MustCompile(`^(clang|clang\+\+|clang-cpp)(-[0-9.]+)?$`),
+		Dirs: []string{"/usr/lib/llvm-*", "/usr/lib/clang"}},
+	{Tool: "go", Kind: Compiler, Commands: regexp.MustCompile(`^(go|gofmt)$`),
+		Dirs: []string{"/usr/local/go", "/usr/lib/go", "/usr/lib/go-*"}},
+	{Tool: "rust", Kind: Compiler, Commands: regexp.MustCompile(`^(rustc|cargo|rustup|rustdoc)$`),
+		Dirs: []string{"/usr/local/cargo", "/usr/local/rustup", "/root/.cargo", "/root/.rustup", "/usr/lib/rustlib"}},
+	{Tool: "javac", Kind: Compiler, Commands: regexp.MustCompile(`^javac$`)},
+	{Tool: "binutils", Kind: BuildTool, Commands: regexp.MustCompile(`^([a-z0-9_]+-[a-z0-9_]+-[a-z0-9_-]+-)?(as|ld|ld\.bfd|ld\.gold)$`)},
+	{Tool: "make", Kind: BuildTool, Commands: regexp.MustCompile(`^g?make$`)},
+	{Tool: "cmake", Kind: BuildTool, Commands: regexp.MustCompile(`^(cmake|ctest|cpack)$`),
+		Dirs: []string{"/usr/share/cmake*", "/usr/local/share/cmake*"}},
+	{Tool: "ninja", Kind: BuildTool, Commands: regexp.MustCompile(`^(ninja|meson)$`)},
+	{Tool: "autotools", Kind: BuildTool, Commands: regexp.MustCompile(`^(autoconf|autoreconf|automake|aclocal|libtool|libtoolize)(-[0-9.]+)?$`),
+		Dirs: []string{"/usr/share/autoconf", "/usr/share/automake*", "/usr/share/libtool"}},
+	{Tool: "pkg-config", Kind: BuildTool, Commands: regexp.MustCompile(`^(pkg-config|pkgconf)$`)},
+	{Tool: "maven", Kind: BuildTool, Commands: regexp.MustCompile(`^mvn$`), Dirs: []string{"/usr/share/maven", "/usr/share/maven-*"}},
+	{Tool: "gradle", Kind: BuildTool, Commands: regexp.MustCompile(`^gradle$`), Dirs: []string{"/opt/gradle", "/opt/gradle-*", "/usr/share/gradle"}},
+}
+
+// Leftover is a build tool found in an image
+type Leftover struct {
+	Tool     string
+	Kind     string
+	Commands []string
+	// Packages are the packages that installed the commands
+	Packages []string `json:",omitempty"`
+	// Managed is true when every command was installed by a package
+	Managed bool
+	// Size is the number of bytes of the commands and of the tool installation directories
+	Size int64
+}
+
+// Find returns the build tools present in the image filesystem, leaving out
+// the files skip reports as out of the scan scope when it is set; owner
+// returns the package that installed a file or an empty string
+func Find(fs *rootfs.FS, skip func(name string) bool, owner func(name string) string, signatures []Signature) []Leftover {
+	type found struct {
+		leftover Leftover
+		counted  map[string]bool
+		packages map[string]bool
+	}
+	tools := make(map[string]*found)
+	add := func(sig Signature, name string, e *rootfs.Entry) *found {
+		f, ok := tools[sig.Tool]
+		if !ok {
+			f = &found{leftover: Leftover{Tool: sig.Tool, Kind: sig.Kind, Managed: true},
+				counted: make(map[string]bool), packages: make(map[string]bool)}
+			tools[sig.Tool] = f
+		}
+		if !f.counted[name] && e.Header.Typeflag == tar.TypeReg {
+			f.counted[name] = true
+			f.leftover.Size += e.Header.Size
+		}
+		return f
+	}
+	fs.Walk(func(name string, e *rootfs.Entry) {
+		if skip != nil && skip(name) {
+			return
+		}
+		for _, sig := range signatures {
+			if inDirs(name, sig.Dirs) {
+				add(sig, name, e)
+			}
+			if !isCommand(name, e) || !sig.Commands.MatchString(path.Base(name)) {
+				continue
+			}
+			f := add(sig, name, e)
+			f.leftover.Commands = append(f.leftover.Commands, name)
+			if pkg := owner(name); pkg != "" {
+				f.packages[pkg] = true
+			} else {
+				f.leftover.Managed = false
+			}
+		}
+	})
+	var leftovers []Leftover
+	for _, f := range tools {
+		// an installation directory without commands, e.g. /usr/lib/gcc holding only runtime libraries
+		if len(f.leftover.Commands) == 0 {
+			continue
+		}
+		for pkg := range f.packages {
+			f.leftover.Packages = append(f.leftover.Packages, pkg)
+		}
+		sort.Strings(f.leftover.Packages)
+		leftovers = append(leftovers, f.leftover)
+	}
+	sort.Slice(leftovers, func(i, j int) bool {
+		if leftovers[i].Size != leftovers[j].Size {
+			return leftovers[i].Size > leftovers[j].Size
+		}
+		return leftovers[i].Tool < leftovers[j].Tool
+	})
+	return leftovers
+}
+
+// isCommand reports whether the entry is an executable file or a symlink in a bin or sbin directory
+func isCommand(name string, e *rootfs.Entry) bool {
+	if dir := path.Base(path.Dir(name)); dir != "bin" && dir != "sbin" {
+		return false
+	}
+	switch e.Header.Typeflag {
+	case tar.TypeSymlink:
+		return true
+	case tar.TypeReg, tar.TypeLink:
+		return e.Header.Mode&0111 != 0
+	}
+	return false
+}
+
+// inDirs reports whether name is below a directory matching one of the patterns
+func inDirs(name string, patterns []string) bool {
+	if len(patterns) == 0 {
+		return false
+	}
+	for d := path.Dir(name); d != "/"; d = path.Dir(d) {
+		for _, p := range patterns {
+			if ok, _ := path.Match(p, d); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package toolchain
+
+import (
+	"archive/tar"
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aquasecurity/binfinder/pkg/rootfs"
+)
+
+type file struct {
+	name     string
+	mode     int64
+	size     int
+	linkname string
+}
+
+func loadFS(t *testing.T, files []file) *rootfs.FS {
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	for _, f := range files {
+		hdr := &tar.Header{Name: f.name, Mode: f.mode, Typeflag: tar.TypeReg, Size: int64(f.size)}
+		if f.linkname != "" {
+			hdr.Typeflag = tar.TypeSymlink
+			hdr.Linkname = f.linkname
+		}
+		require.NoError(t, tw.WriteHeader(hdr))
+		_, err := tw.Write([]byte(strings.Repeat("x", f.size)))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	fs, err := rootfs.Load(buf, "")
+	require.NoError(t, err)
+	return fs
+}
+
+func TestFind(t *testing.T) {
+	fs := loadFS(t, []file{
+		{name: "usr/bin/gcc", linkname: "gcc-10"},
+		{name: "usr/bin/gcc-10", mode: 0755, size: 1000},
+		{name: "usr/bin/x86_64-linux-gnu-gcc-10", mode: 0755, size: 1000},
+		{name: "usr/lib/gcc/x86_64-linux-gnu/10/cc1", mode: 0755, size: 20000},
+		{name: "usr/lib/gcc/x86_64-linux-gnu/10/include/stddef.h", mode: 0644, size: 300},
+		{name: "usr/bin/make", mode: 0755, size: 250},
+		{name: "usr/bin/ldd", mode: 0755, size: 50},
+		{name: "usr/local/go/bin/go", mode: 0755, size: 15000},
+		{name: "usr/local/go/bin/gofmt", mode: 0755, size: 3000},
+		{name: "usr/local/go/src/fmt/print.go", mode: 0644, size: 400},
+		{name: "usr/share/doc/make/README", mode: 0644, size: 10},
+		{name: "usr/local/share/cmake-3.18/Modules/FindZLIB.cmake", mode: 0644, size: 100},
+		{name: "usr/lib/llvm-11/lib/libLLVM-11.so.1", mode: 0644, size: 5000},
+		{name: "opt/app/bin/make.conf", mode: 0644, size: 10},
+	})
+	defer fs.Close()
+
+	owners := map[string]string{
+		"/usr/bin/gcc":                     "gcc",
+		"/usr/bin/gcc-10":                  "gcc-10",
+		"/usr/bin/x86_64-linux-gnu-gcc-10": "gcc-10",
+		"/usr/bin/make":                    "make",
+	}
+	owner := func(name string) string { return owners[name] }
+	assert.Equal(t, []Leftover{
+		{Tool: "gcc", Kind: Compiler, Commands: []string{"/usr/bin/gcc", "/usr/bin/gcc-10", "/usr/bin/x86_64-linux-gnu-gcc-10"},
+			Packages: []string{"gcc", "gcc-10"}, Managed: true, Size: 22300},
+		{Tool: "go", Kind: Compiler, Commands: []string{"/usr/local/go/bin/go", "/usr/local/go/bin/gofmt"}, Size: 18400},
+		{Tool: "make", Kind: BuildTool, Commands: []string{"/usr/bin/make"}, Packages: []string{"make"}, Managed: true, Size: 250},
+	}, Find(fs, nil, owner, Signatures))
+
+	// the files out of the scan scope are not considered
+	skip := func(name string) bool { return strings.HasPrefix(name, "/usr/local/") }
+	assert.Equal(t, []Leftover{
+		{Tool: "gcc", Kind: Compiler, Commands: []string{"/usr/bin/gcc", "/usr/bin/gcc-10", "/usr/bin/x86_64-linux-gnu-gcc-10"},
+			Packages: []string{"gcc", "gcc-10"}, Managed: true, Size: 22300},
+		{Tool: "make", Kind: BuildTool, Commands: []string{"/usr/bin/make"}, Packages: []string{"make"}, Managed: true, Size: 250},
+	}, Find(fs, skip, owner, Signatures))
+}
@@ -0,0 +1,140 @@
+package versions
+
+import (
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Confidence levels of the candidates
+const (
+	ConfidenceHigh   = "high"
+	ConfidenceMedium = "medium"
+	ConfidenceLow    = "low"
+)
+
+// Candidate is a project version found in the strings of a binary
+type Candidate struct {
+	Project    string
+	Version    string
+	Confidence string
+	// Library is set for banners of libraries that may be statically linked
+	Library bool `json:",omitempty"`
+	// Evidence is the string the version was found in
+	Evidence string
+}
+
+// banner is a version string known to be embedded by a library
+type banner struct {
+	project string
+	re      *regexp.Regexp
+}
+
+// banners are the version strings compiled into the libraries, the first
+// submatch is the version
+var banners = []banner{
+	{"openssl", regexp.MustCompile(`OpenSSL (\d+\.\d+\.\d+[a-z]{0,2})(?:-fips|-dev)?\s+\d{1,2} [A-Z][a-z]{2} \d{4}`)},
+	{"zlib", regexp.MustCompile(`(?:deflate|inflate) (\d+\.\d+\.\d+(?:\.\d+)?) Copyright 1995-\d{4}`)},
+	{"curl", regexp.MustCompile(`libcurl/(\d+\.\d+\.\d+)`)},
+	{"libssh2", regexp.MustCompile(`libssh2/(\d+\.\d+\.\d+)`)},
+	{"pcre", regexp.MustCompile(`PCRE(?:2)? (\d+\.\d+) \d{4}-\d{2}-\d{2}`)},
+}
+
+var (
+	// sqliteSourceID is SQLITE_SOURCE_ID, the version is stored nearby
+	sqliteSourceID = regexp.MustCompile(`\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2} [0-9a-f]{40,64}`)
+	sqliteVersion  = regexp.MustCompile(`\b(3\.\d{1,2}\.\d{1,2})\x00`)
+	// sqliteWindow is how far from the source ID the version is looked for
+	sqliteWindow = 512
+
+	// projectVersion is a name followed by a version, e.g. "nginx/1.19.2" or "Redis 6.0.5"
+	projectVersion = regexp.MustCompile(`([A-Za-z][A-Za-z0-9_-]{1,30})[ /]v?(\d+\.\d+\.\d+(?:[.-][0-9A-Za-z]+)?)\b`)
+	// versionKeyword is a version introduced by a keyword, e.g. "version 1.2.3"
+	versionKeyword = regexp.MustCompile(`(?i)\bversion:? v?(\d+\.\d+\.\d+(?:[.-][0-9A-Za-z]+)?)\b`)
+)
+
+const (
+	// maxEvidence is the longest evidence string kept
+	maxEvidence = 120
+	// maxLow is the most low confidence candidates reported for a binary
+	maxLow = 3
+)
+
+// Extract returns the project versions found in the read-only data of the
+// binary at name. Library banners are reported with high confidence, versions
+// following the name of the binary with medium and versions following a
+// version keyword with low confidence.
+func Extract(data []byte, name string) []Candidate {
+	var candidates []Candidate
+	seen := make(map[string]bool)
+	seenVersions := make(map[string]bool)
+	add := func(c Candidate) {
+		key := c.Project + "\x00" + c.Version
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		seenVersions[c.Version] = true
+		if len(c.Evidence) > maxEvidence {
+			c.Evidence = c.Evidence[:maxEvidence]
+		}
+		candidates = append(candidates, c)
+	}
+
+	for _, b := range banners {
+		for _, m := range b.re.FindAllSubmatch(data, -1) {
+			add(Candidate{Project: b.project, Version: string(m[1]), Confidence: ConfidenceHigh, Library: true,
+				Evidence: string(m[0])})
+		}
+	}
+	for _, loc := range sqliteSourceID.FindAllIndex(data, -1) {
+		start, end := loc[0]-sqliteWindow, loc[1]+sqliteWindow
+		if start < 0 {
+			start = 0
+		}
+		if end > len(data) {
+			end = len(data)
+		}
+		if m := sqliteVersion.FindSubmatch(data[start:end]); m != nil {
+			add(Candidate{Project: "sqlite", Version: string(m[1]), Confidence: ConfidenceMedium, Library: true,
+				Evidence: string(data[loc[0]:loc[1]])})
+		}
+	}
+
+	base := strings.ToLower(path.Base(name))
+	for _, m := range projectVersion.FindAllSubmatch(data, -1) {
+		project := strings.ToLower(string(m[1]))
+		if project == base || strings.HasPrefix(base, project+"-") || strings.HasPrefix(base, project+"_") {
+			add(Candidate{Project: project, Version: string(m[2]), Confidence: ConfidenceMedium,
+				Evidence: string(m[0])})
+		}
+	}
+	low := 0
+	for _, m := range versionKeyword.FindAllSubmatch(data, -1) {
+		if low == maxLow {
+			break
+		}
+		// a version already found with a better confidence
+		if seenVersions[string(m[1])] {
+			continue
+		}
+		add(Candidate{Project: base, Version: string(m[1]), Confidence: ConfidenceLow, Evidence: string(m[0])})
+		low++
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return rank(candidates[i].Confidence) < rank(candidates[j].Confidence)
+	})
+	return candidates
+}
+
+func rank(confidence string) int {
+	switch confidence {
+	case ConfidenceHigh:
+		return 0
+	case ConfidenceMedium:
+		return 1
+	}
+	return 2
+}
@@ -0,0 +1,64 @@
+package versions
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtract(t *testing.T) {
+	testCases := []struct {
+		name   string
+		binary string
+		data   string
+		want   []Candidate
+	}{
+		{
+			name:   "statically linked libraries",
+			binary: "/usr/local/bin/app",
+			data: "\x00OpenSSL 1.1.1g  21 Apr 2020\x00 deflate 1.2.11 Copyright 1995-2017 Jean-loup Gailly and Mark Adler \x00" +
+				"libcurl/7.68.0\x00" + "3.31.1\x00\x00" +
+				"2020-01-27 19:55:54 3bfa9cc97da10598521b342961df8f5f68c7388fa117345eeb516eaa837bb4d6\x00",
+			want: []Candidate{
+				{Project: "openssl", Version: "1.1.1g", Confidence: ConfidenceHigh, Library: true,
+					Evidence: "OpenSSL 1.1.1g  21 Apr 2020"},
+				{Project: "zlib", Version: "1.2.11", Confidence: ConfidenceHigh, Library: true,
+					Evidence: "deflate 1.2.11 Copyright 1995-2017"},
+				{Project: "curl", Version: "7.68.0", Confidence: ConfidenceHigh, Library: true,
+					Evidence: "libcurl/7.68.0"},
+				{Project: "sqlite", Version: "3.31.1", Confidence: ConfidenceMedium, Library: true,
+					Evidence: "2020-01-27 19:55:54 3bfa9cc97da10598521b342961df8f5f68c7388fa117345eeb516eaa837bb4d6"},
+			},
+		},
+		{
+			name:   "binary name and version keyword",
+			binary: "/usr/local/bin/redis-server",
+			data:   "\x00Redis 6.0.5 (00000000/0) 64 bit\x00Usage: version 6.0.5\x00protocol version: 2.1.0\x00",
+			want: []Candidate{
+				{Project: "redis", Version: "6.0.5", Confidence: ConfidenceMedium, Evidence: "Redis 6.0.5"},
+				{Project: "redis-server", Version: "2.1.0", Confidence: ConfidenceLow, Evidence: "version: 2.1.0"},
+			},
+		},
+		{
+			name:   "other project names are ignored",
+			binary: "/usr/local/bin/gosu",
+			data:   "\x00github.com/opencontainers/runc 1.0.0\x00",
+		},
+		{
+			name:   "low confidence candidates are capped",
+			binary: "/opt/tool",
+			data:   strings.Repeat("version 1.0.0\x00version 1.0.1\x00version 1.0.2\x00version 1.0.3\x00", 2),
+			want: []Candidate{
+				{Project: "tool", Version: "1.0.0", Confidence: ConfidenceLow, Evidence: "version 1.0.0"},
+				{Project: "tool", Version: "1.0.1", Confidence: ConfidenceLow, Evidence: "version 1.0.1"},
+				{Project: "tool", Version: "1.0.2", Confidence: ConfidenceLow, Evidence: "version 1.0.2"},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, Extract([]byte(tc.data), tc.binary))
+		})
+	}
+}
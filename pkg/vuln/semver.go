@@ -0,0 +1,84 @@
+package vuln
+
+import (
+	"strconv"
+	"strings"
+)
+
+// version is a parsed semantic version, missing minor and patch numbers are zero
+type version struct {
+	numbers    [3]int
+	prerelease []string
+}
+
+// parseVersion parses a semantic version, with an optional v prefix
+func parseVersion(s string) (version, bool) {
+	var v version
+	s = strings.TrimPrefix(s, "v")
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		s = s[:i]
+	}
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		v.prerelease = strings.Split(s[i+1:], ".")
+		s = s[:i]
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 || parts[0] == "" {
+		return v, false
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return v, false
+		}
+		v.numbers[i] = n
+	}
+	return v, true
+}
+
+// compare returns -1, 0 or 1 following the semantic versioning precedence rules
+func (v version) compare(o version) int {
+	for i := range v.numbers {
+		if c := compareInt(v.numbers[i], o.numbers[i]); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case len(v.prerelease) == 0 && len(o.prerelease) == 0:
+		return 0
+	case len(v.prerelease) == 0:
+		return 1
+	case len(o.prerelease) == 0:
+		return -1
+	}
+	for i := 0; i < len(v.prerelease) && i < len(o.prerelease); i++ {
+		a, b := v.prerelease[i], o.prerelease[i]
+		an, aErr := strconv.Atoi(a)
+		bn, bErr := strconv.Atoi(b)
+		var c int
+		switch {
+		case aErr == nil && bErr == nil:
+			c = compareInt(an, bn)
+		case aErr == nil:
+			c = -1
+		case bErr == nil:
+			c = 1
+		default:
+			c = strings.Compare(a, b)
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return compareInt(len(v.prerelease), len(o.prerelease))
+}
+
+func compareInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,249 @@
+package vuln
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// OSV ecosystems of the components binfinder identifies
+const (
+	EcosystemGo    = "Go"
+	EcosystemCrate = "crates.io"
+	// GoStdlib is the OSV package name of the Go standard library
+	GoStdlib = "stdlib"
+)
+
+// Component is a versioned piece of software identified in a binary
+type Component struct {
+	Ecosystem string
+	Name      string
+	Version   string
+}
+
+// Vulnerability is an advisory affecting a component of a binary
+type Vulnerability struct {
+	ID        string
+	Aliases   []string `json:",omitempty"`
+	Summary   string   `json:",omitempty"`
+	Severity  string   `json:",omitempty"`
+	Component Component
+	// Fixed are the versions fixing the advisory
+	Fixed []string `json:",omitempty"`
+}
+
+// advisory is the part of the OSV schema binfinder uses
+type advisory struct {
+	ID       string
+	Aliases  []string
+	Summary  string
+	Severity []struct {
+		Type  string
+		Score string
+	}
+	Affected []struct {
+		Package struct {
+			Ecosystem string
+			Name      string
+		}
+		Ranges []struct {
+			Type   string
+			Events []event
+		}
+		Versions         []string
+		DatabaseSpecific struct {
+			Severity string
+		} `json:"database_specific"`
+	}
+	DatabaseSpecific struct {
+		Severity string
+	} `json:"database_specific"`
+}
+
+type event struct {
+	Introduced   string
+	Fixed        string
+	LastAffected string `json:"last_affected"`
+	Limit        string
+}
+
+// DB is an offline copy of OSV advisories
+type DB struct {
+	advisories map[string][]*advisory
+}
+
+func packageKey(ecosystem, name string) string {
+	return ecosystem + "\x00" + name
+}
+
+// Load reads every OSV JSON file of dir and its subdirectories, such as an
+// extracted osv.dev ecosystem all.zip
+func Load(dir string) (*DB, error) {
+	db := &DB{advisories: make(map[string][]*advisory)}
+	err := filepath.Walk(dir, func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !strings.HasSuffix(name, ".json") {
+			return nil
+		}
+		b, err := ioutil.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		a := &advisory{}
+		if err = json.Unmarshal(b, a); err != nil {
+			return fmt.Errorf("%v: invalid OSV advisory: %v", name, err)
+		}
+		db.add(a)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+func (db *DB) add(a *advisory) {
+	seen := make(map[string]bool)
+	for _, af := range a.Affected {
+		key := packageKey(af.Package.Ecosystem, af.Package.Name)
+		if !seen[key] {
+			seen[key] = true
+			db.advisories[key] = append(db.advisories[key], a)
+		}
+	}
+}
+
+// Size returns the number of advisories indexed per package
+func (db *DB) Size() int {
+	n := 0
+	for _, a := range db.advisories {
+		n += len(a)
+	}
+	return n
+}
+
+// Match returns the advisories affecting the components, sorted by ID
+func (db *DB) Match(components []Component) []Vulnerability {
+	var vulns []Vulnerability
+	for _, c := range components {
+		for _, a := range db.advisories[packageKey(c.Ecosystem, c.Name)] {
+			if v, ok := a.affects(c); ok {
+				vulns = append(vulns, v)
+			}
+		}
+	}
+	sort.SliceStable(vulns, func(i, j int) bool { return vulns[i].ID < vulns[j].ID })
+	return vulns
+}
+
+func (a *advisory) affects(c Component) (Vulnerability, bool) {
+	v := Vulnerability{ID: a.ID, Aliases: a.Aliases, Summary: a.Summary, Component: c}
+	affected := false
+	for _, af := range a.Affected {
+		if af.Package.Ecosystem != c.Ecosystem || af.Package.Name != c.Name {
+			continue
+		}
+		for _, listed := range af.Versions {
+			if listed == c.Version {
+				affected = true
+			}
+		}
+		for _, r := range af.Ranges {
+			if r.Type != "SEMVER" && !(r.Type == "ECOSYSTEM" && semverEcosystem(c.Ecosystem)) {
+				continue
+			}
+			if inRange(c.Version, r.Events) {
+				affected = true
+			}
+			for _, e := range r.Events {
+				if e.Fixed != "" && !contains(v.Fixed, e.Fixed) {
+					v.Fixed = append(v.Fixed, e.Fixed)
+				}
+			}
+		}
+		if affected && v.Severity == "" {
+			v.Severity = af.DatabaseSpecific.Severity
+		}
+	}
+	if !affected {
+		return v, false
+	}
+	if v.Severity == "" {
+		v.Severity = a.DatabaseSpecific.Severity
+	}
+	if v.Severity == "" && len(a.Severity) > 0 {
+		v.Severity = a.Severity[0].Score
+	}
+	return v, true
+}
+
+func contains(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// semverEcosystem reports whether the ECOSYSTEM ranges of the ecosystem use semantic versions
+func semverEcosystem(ecosystem string) bool {
+	return ecosystem == EcosystemGo || ecosystem == EcosystemCrate
+}
+
+// inRange evaluates the events of an OSV range for the version
+func inRange(s string, events []event) bool {
+	v, ok := parseVersion(s)
+	if !ok {
+		return false
+	}
+	type point struct {
+		version version
+		event   event
+	}
+	var points []point
+	for _, e := range events {
+		p := point{event: e}
+		var raw string
+		switch {
+		case e.Introduced != "":
+			raw = e.Introduced
+		case e.Fixed != "":
+			raw = e.Fixed
+		case e.LastAffected != "":
+			raw = e.LastAffected
+		case e.Limit != "":
+			raw = e.Limit
+		}
+		if raw == "0" {
+			raw = "0.0.0-0"
+		}
+		if p.version, ok = parseVersion(raw); !ok {
+			continue
+		}
+		points = append(points, p)
+	}
+	sort.SliceStable(points, func(i, j int) bool { return points[i].version.compare(points[j].version) < 0 })
+
+	affected := false
+	for _, p := range points {
+		c := v.compare(p.version)
+		switch {
+		case p.event.Introduced != "" && c >= 0:
+			affected = true
+		case p.event.Fixed != "" && c >= 0:
+			affected = false
+		case p.event.LastAffected != "" && c > 0:
+			affected = false
+		case p.event.Limit != "" && c >= 0:
+			affected = false
+		}
+	}
+	return affected
+}
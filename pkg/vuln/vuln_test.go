@@ -0,0 +1,82 @@
+package vuln
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_Match(t *testing.T) {
+	db, err := Load("testdata/osv")
+	require.NoError(t, err)
+	assert.Equal(t, 4, db.Size())
+
+	testCases := []struct {
+		name      string
+		component Component
+		want      []Vulnerability
+	}{
+		{
+			name:      "go module before fix",
+			component: Component{Ecosystem: EcosystemGo, Name: "github.com/opencontainers/runc", Version: "0.1.1"},
+			want: []Vulnerability{{ID: "GO-2021-0085", Aliases: []string{"CVE-2019-19921", "GHSA-fh74-hm69-rqjw"},
+				Summary: "Volume mount race condition in runc", Severity: "MODERATE", Fixed: []string{"1.0.0-rc10"}}},
+		},
+		{
+			name:      "go module fixed",
+			component: Component{Ecosystem: EcosystemGo, Name: "github.com/opencontainers/runc", Version: "1.0.0-rc10"},
+		},
+		{
+			name:      "stdlib in second range",
+			component: Component{Ecosystem: EcosystemGo, Name: GoStdlib, Version: "1.19.0"},
+			want: []Vulnerability{{ID: "GO-2022-0969", Aliases: []string{"CVE-2022-27664"},
+				Summary: "Denial of service in net/http", Severity: "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:N/I:N/A:H",
+				Fixed: []string{"1.18.6", "1.19.1"}}},
+		},
+		{
+			name:      "stdlib between ranges",
+			component: Component{Ecosystem: EcosystemGo, Name: GoStdlib, Version: "1.18.7"},
+		},
+		{
+			name:      "crate",
+			component: Component{Ecosystem: EcosystemCrate, Name: "regex", Version: "1.5.4"},
+			want: []Vulnerability{{ID: "RUSTSEC-2022-0013", Aliases: []string{"CVE-2022-24713", "GHSA-m5pq-gvj9-9vr8"},
+				Summary:  "Regexes with large repetitions on empty sub-expressions take a very long time to parse",
+				Severity: "HIGH", Fixed: []string{"1.5.5"}}},
+		},
+		{
+			name:      "listed version",
+			component: Component{Ecosystem: EcosystemCrate, Name: "smallvec", Version: "0.6.13"},
+			want:      []Vulnerability{{ID: "RUSTSEC-2020-0001", Summary: "Listed versions only"}},
+		},
+		{
+			name:      "other ecosystem",
+			component: Component{Ecosystem: "npm", Name: "regex", Version: "1.5.4"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for i := range tc.want {
+				tc.want[i].Component = tc.component
+			}
+			assert.Equal(t, tc.want, db.Match([]Component{tc.component}))
+		})
+	}
+}
+
+func TestVersion_compare(t *testing.T) {
+	ordered := []string{"0.0.0-0", "0.9", "1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-beta.2", "1.0.0-beta.11",
+		"1.0.0-rc.1", "1.0.0", "v1.0.1", "1.2.0+build", "1.10.0"}
+	for i := range ordered {
+		for j := range ordered {
+			a, ok := parseVersion(ordered[i])
+			require.True(t, ok, ordered[i])
+			b, ok := parseVersion(ordered[j])
+			require.True(t, ok, ordered[j])
+			assert.Equal(t, compareInt(i, j), a.compare(b), "%v <=> %v", ordered[i], ordered[j])
+		}
+	}
+	_, ok := parseVersion("abcdef")
+	assert.False(t, ok)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+)
+
+// argsProbeBinary runs an unmanaged binary in a container without network,
+// with a read-only root filesystem, no capabilities and limited resources
+var argsProbeBinary = `run --rm --name %v --network none --read-only --cap-drop ALL --security-opt no-new-privileges ` +
+	`-u 65534:65534 --memory 64m --memory-swap 64m --cpus 0.5 --pids-limit 32 --entrypoint %v %v`
+
+// maxProbeOutput is the longest first line kept from a probe
+const maxProbeOutput = 200
+
+// Probe is the version line printed by a binary
+type Probe struct {
+	// Args are the arguments the binary printed its version for
+	Args   string
+	Output string
+}
+
+// probeCommand returns the docker arguments running the binary with the probe arguments
+func probeCommand(container, imageName, binary, probe string) []string {
+	args := strings.Split(fmt.Sprintf(argsProbeBinary, container, binary, imageName), " ")
+	return append(args, strings.Fields(probe)...)
+}
+
+// firstLine returns the first non-empty line of the output
+func firstLine(out []byte) string {
+	s := bufio.NewScanner(bytes.NewReader(out))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" {
+			continue
+		}
+		if len(line) > maxProbeOutput {
+			line = line[:maxProbeOutput]
+		}
+		return line
+	}
+	return ""
+}
+
+// probeVersion runs the binary with each probe in turn and returns the first
+// line printed by the first probe exiting successfully
+func probeVersion(imageName, binary string) *Probe {
+	for _, probe := range probeArgs {
+		out, err := runProbe(imageName, binary, probe)
+		if err != nil {
+			continue
+		}
+		if line := firstLine(out); line != "" {
+			return &Probe{Args: probe, Output: line}
+		}
+	}
+	return nil
+}
+
+func runProbe(imageName, binary, probe string) ([]byte, error) {
+	suffix := make([]byte, 6)
+	_, _ = rand.Read(suffix)
+	container := "binfinder-probe-" + hex.EncodeToString(suffix)
+
+	ctx, cancel := context.WithTimeout(context.Background(), *probeTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "docker", probeCommand(container, imageName, binary, probe)...).CombinedOutput()
+	if ctx.Err() != nil {
+		// killing the docker client leaves the container running
+		_ = exec.Command("docker", strings.Split(fmt.Sprintf(argsRemoveContainer, container), " ")...).Run()
+		return nil, ctx.Err()
+	}
+	return out, err
+}
+
+// probeBinaries records the version printed by every unmanaged binary
+func (s *imageScan) probeBinaries() {
+	if !*probe {
+		return
+	}
+	for i := range s.diff.Binaries {
+		b := &s.diff.Binaries[i]
+		if b.Probe = probeVersion(s.diff.ImageName, b.Path); b.Probe != nil {
+			log.Printf("%v: %v %v: %v\n", s.diff.ImageName, b.Path, b.Probe.Args, b.Probe.Output)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"log"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/aquasecurity/binfinder/pkg/distindex"
+	"github.com/aquasecurity/binfinder/pkg/pkgdb"
+)
+
+// Remediation suggests the distribution packages to install in place of unmanaged binaries
+type Remediation struct {
+	// Release is the -distro-index release directory the packages come from
+	Release string
+	// Packages are the first package found for each binary the distribution ships
+	Packages []string
+	// Snippet is the Dockerfile instruction installing the packages
+	Snippet string
+}
+
+var (
+	distroIndexesMu sync.Mutex
+	// distroIndexes are the loaded -distro-index releases, nil for the ones failing to load
+	distroIndexes = make(map[string]*distindex.Index)
+)
+
+// distroIndex returns the package index of the release, loaded once and shared by the workers
+func distroIndex(release string) (*distindex.Index, string) {
+	dir, ok := pkgdb.Find(*distroIndexDir, release)
+	if !ok {
+		return nil, ""
+	}
+	distroIndexesMu.Lock()
+	defer distroIndexesMu.Unlock()
+	if ix, ok := distroIndexes[dir]; ok {
+		return ix, filepath.Base(dir)
+	}
+	ix, err := distindex.Load(dir)
+	if err != nil {
+		log.Printf("error loading distribution package index: %v", err)
+	}
+	distroIndexes[dir] = ix
+	return ix, filepath.Base(dir)
+}
+
+// readRelease records the release of the image whose -distro-index the
+// unmanaged binaries are looked up in
+func (s *imageScan) readRelease() {
+	if *distroIndexDir == "" {
+		return
+	}
+	content, err := s.fs.ReadFile("/etc/os-release")
+	if err != nil {
+		log.Printf("%v: error reading release: %v\n", s.diff.ImageName, err)
+		return
+	}
+	s.diff.release = pkgdb.Release(content)
+}
+
+// suggestPackages looks up the binaries left in ELFNames, once the declared
+// and inherited ones are taken out, in the -distro-index of the image release
+func suggestPackages(d *Diffs) {
+	if d.release == "" {
+		return
+	}
+	ix, dir := distroIndex(d.release)
+	if ix == nil {
+		log.Printf("%v: no distribution package index for %v\n", d.ImageName, d.release)
+		return
+	}
+	listed := make(map[string]bool)
+	for _, p := range d.ELFNames {
+		listed[p] = true
+	}
+	suggested := make(map[string]bool)
+	for i := range d.Binaries {
+		b := &d.Binaries[i]
+		if !listed[b.Path] {
+			continue
+		}
+		if b.DistroPackages = ix.Lookup(b.Path); len(b.DistroPackages) > 0 {
+			suggested[b.DistroPackages[0].Package] = true
+		}
+	}
+	if len(suggested) == 0 {
+		return
+	}
+	r := &Remediation{Release: dir}
+	for p := range suggested {
+		r.Packages = append(r.Packages, p)
+	}
+	sort.Strings(r.Packages)
+	r.Snippet = distindex.Snippet(ix.Manager, r.Packages)
+	d.Remediation = r
+}